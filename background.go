@@ -0,0 +1,224 @@
+package captcha
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"math"
+	"math/rand"
+
+	xdraw "golang.org/x/image/draw"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// Background 验证码背景，在绘制字符之前填充整张画布
+type Background interface {
+	Draw(img *image.RGBA)
+}
+
+// SolidBackground 纯色背景
+type SolidBackground struct {
+	Color color.RGBA
+}
+
+// LinearGradient 线性渐变背景
+type LinearGradient struct {
+	From, To    color.RGBA
+	Angle       float64 // 渐变方向（度），0 表示从左到右
+	RandomAngle bool    // 为 true 时忽略 Angle，每张图片随机选择方向
+}
+
+// RadialGradient 径向渐变背景，圆心在图片中心附近随机选择
+type RadialGradient struct {
+	Inner, Outer color.RGBA
+}
+
+// NoiseTexture 程序化噪声纹理背景（类 Perlin 的分形值噪声），在 Base 和 Accent 两种颜色之间变化
+type NoiseTexture struct {
+	Base, Accent color.RGBA
+	Scale        float64 // 最低频噪声的格子大小（像素），默认16
+	Octaves      int     // 叠加的倍频数量，默认3
+}
+
+// ImageBackground 从用户提供的背景图片中随机裁剪一块作为背景
+type ImageBackground struct {
+	Images []image.Image
+}
+
+// Draw 实现 Background 接口
+func (b SolidBackground) Draw(img *image.RGBA) {
+	fillRGBA(img, b.Color)
+}
+
+// Draw 实现 Background 接口
+func (b LinearGradient) Draw(img *image.RGBA) {
+	angle := b.Angle
+	if b.RandomAngle {
+		angle = rand.Float64() * 360
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+
+	// 将每个像素投影到渐变方向上，并归一化到 [0, 1]
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	span := math.Abs(w*cos) + math.Abs(h*sin)
+	offset := math.Min(0, w*cos) + math.Min(0, h*sin)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			t := ((float64(x)+0.5)*cos + (float64(y)+0.5)*sin - offset) / span
+			setPixel(img, x, y, lerpColor(b.From, b.To, t))
+		}
+	}
+}
+
+// Draw 实现 Background 接口
+func (b RadialGradient) Draw(img *image.RGBA) {
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	cx := w/2 + (rand.Float64()-0.5)*w/3
+	cy := h/2 + (rand.Float64()-0.5)*h/3
+	radius := math.Max(math.Hypot(cx, cy), math.Hypot(w-cx, h-cy))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			t := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / radius
+			setPixel(img, x, y, lerpColor(b.Inner, b.Outer, t))
+		}
+	}
+}
+
+// Draw 实现 Background 接口
+func (b NoiseTexture) Draw(img *image.RGBA) {
+	scale := b.Scale
+	if scale <= 0 {
+		scale = 16
+	}
+	octaves := b.Octaves
+	if octaves <= 0 {
+		octaves = 3
+	}
+
+	// 每个倍频使用独立的随机格点值
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	layers := make([]valueNoise, octaves)
+	for i := range layers {
+		cell := scale / math.Pow(2, float64(i))
+		layers[i] = newValueNoise(int(float64(w)/cell)+2, int(float64(h)/cell)+2, cell)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var v, total float64
+			amplitude := 1.0
+			for _, layer := range layers {
+				v += layer.at(float64(x), float64(y)) * amplitude
+				total += amplitude
+				amplitude /= 2
+			}
+			setPixel(img, x, y, lerpColor(b.Base, b.Accent, v/total))
+		}
+	}
+}
+
+// Draw 实现 Background 接口
+func (b ImageBackground) Draw(img *image.RGBA) {
+	if len(b.Images) == 0 {
+		fillRGBA(img, color.RGBA{255, 255, 255, 255})
+		return
+	}
+	src := b.Images[rand.Intn(len(b.Images))]
+	sb := src.Bounds()
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	// 背景图片比画布小时整体缩放，否则随机裁剪一块与画布同样大小的区域
+	crop := sb
+	if sb.Dx() >= w && sb.Dy() >= h {
+		x0 := sb.Min.X + rand.Intn(sb.Dx()-w+1)
+		y0 := sb.Min.Y + rand.Intn(sb.Dy()-h+1)
+		crop = image.Rect(x0, y0, x0+w, y0+h)
+	}
+	xdraw.ApproxBiLinear.Scale(img, img.Rect, src, crop, xdraw.Src, nil)
+}
+
+// LoadImageBackground 加载 fsys 中所有匹配 pattern（fs.Glob 语法）的图片作为背景
+func LoadImageBackground(fsys fs.FS, pattern string) (ImageBackground, error) {
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return ImageBackground{}, err
+	}
+	if len(matches) == 0 {
+		return ImageBackground{}, fmt.Errorf("no background images match pattern %q", pattern)
+	}
+
+	var bg ImageBackground
+	for _, name := range matches {
+		img, err := loadImage(fsys, name)
+		if err != nil {
+			log.Error("Failed to load background image %s: %v", name, err)
+			return ImageBackground{}, fmt.Errorf("load background %s: %w", name, err)
+		}
+		bg.Images = append(bg.Images, img)
+	}
+	log.Info("Loaded %d background image(s)", len(bg.Images))
+	return bg, nil
+}
+
+// 格点上随机取值、格点间平滑插值的二维值噪声
+type valueNoise struct {
+	values []float64
+	cols   int
+	cell   float64
+}
+
+func newValueNoise(cols, rows int, cell float64) valueNoise {
+	values := make([]float64, cols*rows)
+	for i := range values {
+		values[i] = rand.Float64()
+	}
+	return valueNoise{values: values, cols: cols, cell: math.Max(1, cell)}
+}
+
+func (n valueNoise) at(x, y float64) float64 {
+	fx, fy := x/n.cell, y/n.cell
+	x0, y0 := int(fx), int(fy)
+	tx, ty := smoothstep(fx-float64(x0)), smoothstep(fy-float64(y0))
+
+	v00 := n.values[y0*n.cols+x0]
+	v10 := n.values[y0*n.cols+x0+1]
+	v01 := n.values[(y0+1)*n.cols+x0]
+	v11 := n.values[(y0+1)*n.cols+x0+1]
+	top := v00 + (v10-v00)*tx
+	bottom := v01 + (v11-v01)*tx
+	return top + (bottom-top)*ty
+}
+
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// 在两种颜色之间线性插值，t 超出 [0, 1] 时截断
+func lerpColor(a, b color.RGBA, t float64) color.RGBA {
+	t = max(0, min(1, t))
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// 计算图片区域内的平均颜色，区域与图片不相交时返回 false
+func averageColor(img *image.RGBA, r image.Rectangle) (color.RGBA, bool) {
+	r = r.Intersect(img.Rect)
+	if r.Empty() {
+		return color.RGBA{}, false
+	}
+	var sum [4]uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			for c := 0; c < 4; c++ {
+				sum[c] += uint64(row[i+c])
+			}
+		}
+	}
+	n := uint64(r.Dx() * r.Dy())
+	return color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)}, true
+}
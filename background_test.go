@@ -0,0 +1,71 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestContrastRatio(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}
+	if got := contrastRatio(black, white); math.Abs(got-21) > 1e-9 {
+		t.Fatalf("黑白对比度应为21，实际为 %v", got)
+	}
+	if got := contrastRatio(white, white); got != 1 {
+		t.Fatalf("相同颜色的对比度应为1，实际为 %v", got)
+	}
+}
+
+func TestEnsureContrast(t *testing.T) {
+	backgrounds := []color.RGBA{{255, 255, 255, 255}, {20, 20, 30, 255}, {128, 128, 128, 255}}
+	for _, bg := range backgrounds {
+		col := ensureContrast(bg, bg, 4.5)
+		if got := contrastRatio(col, bg); got < 4.5 {
+			t.Fatalf("背景 %v 上的字符颜色 %v 对比度仅为 %v", bg, col, got)
+		}
+	}
+}
+
+func TestBackgrounds(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	img := newCanvas(100, 10)
+	LinearGradient{From: red, To: blue}.Draw(img)
+	if got := img.RGBAAt(0, 5); got.R < 250 || got.B > 5 {
+		t.Fatalf("渐变左端应接近起始色，实际为 %v", got)
+	}
+	if got := img.RGBAAt(99, 5); got.B < 250 || got.R > 5 {
+		t.Fatalf("渐变右端应接近结束色，实际为 %v", got)
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, 300, 80))
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+3] = 200, 255
+	}
+	ImageBackground{Images: []image.Image{src}}.Draw(img)
+	if got := img.RGBAAt(50, 5); got.R != 200 {
+		t.Fatalf("背景应从图片中裁剪，实际为 %v", got)
+	}
+
+	NoiseTexture{Base: red, Accent: blue}.Draw(img)
+	if got := img.RGBAAt(50, 5); got.G != 0 || got.A != 255 {
+		t.Fatalf("噪声纹理应在两种颜色之间变化，实际为 %v", got)
+	}
+}
+
+func TestCreateCaptchaImageWithBackground(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	SetImageConfig(ImageConfig{
+		Background:  RadialGradient{Inner: color.RGBA{30, 30, 40, 255}, Outer: color.RGBA{0, 0, 0, 255}},
+		MinContrast: 4.5,
+	})
+	img, err := createCaptchaImage("abcd", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	if img.RGBAAt(1, 1).R > 40 {
+		t.Fatalf("应使用配置的深色背景，实际为 %v", img.RGBAAt(1, 1))
+	}
+}
@@ -4,44 +4,108 @@ import (
 	"bytes"
 	"encoding/base64"
 	"image"
-	"image/png"
+	"io"
 	"math/rand"
-	"os"
 	"time"
 
 	log "github.com/yowaimono/captcha/internal/log"
 )
 
 // GetOne 生成一张验证码图片，并返回验证码ID和base64编码的图片
-func GetOne(length int, format CaptchaFormat) (string, string, error) {
-	log.Info("GetOne called with length: %d, format: %v", length, format)
-	return GetBase64(length, format)
+func GetOne(length int, format CaptchaFormat, noiseLevel NoiseLevel) (string, string, error) {
+	log.Info("GetOne called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
+	return GetBase64(length, format, noiseLevel)
 }
 
 // GetBase64 生成一张验证码图片，并返回验证码ID和base64编码的图片
-func GetBase64(length int, format CaptchaFormat) (string, string, error) {
-	log.Info("GetBase64 called with length: %d, format: %v", length, format)
+//
+// 图片格式由 ImageConfig.Format 决定，默认 PNG；需要带 MIME 类型前缀时使用 GetDataURI。
+func GetBase64(length int, format CaptchaFormat, noiseLevel NoiseLevel) (string, string, error) {
+	log.Info("GetBase64 called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
+
+	captchaID, data, _, err := GetBytes(length, format, noiseLevel)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 将图片编码为base64
+	imgBase64 := base64.StdEncoding.EncodeToString(data)
+	log.Info("Encoded image to base64")
+
+	return captchaID, imgBase64, nil
+}
+
+// GetDataURI 生成一张验证码图片，并返回验证码ID和可直接用于 <img src> 的 data URI
+func GetDataURI(length int, format CaptchaFormat, noiseLevel NoiseLevel) (string, string, error) {
+	log.Info("GetDataURI called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
+
+	captchaID, data, mimeType, err := GetBytes(length, format, noiseLevel)
+	if err != nil {
+		return "", "", err
+	}
+	return captchaID, "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// GetBytes 生成一张验证码图片，并返回验证码ID、编码后的图片数据和 MIME 类型
+func GetBytes(length int, format CaptchaFormat, noiseLevel NoiseLevel) (string, []byte, string, error) {
+	log.Info("GetBytes called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
 
 	// 生成指定长度和格式的随机验证码
 	code := generateCaptchaCode(length, format)
 	log.Info("Generated captcha code: %s", code)
 
-	// 创建验证码图片
-	img := createCaptchaImage(code)
-	log.Info("Created captcha image")
-
-	// 将图片编码为PNG格式
+	// 创建验证码图片，并按配置的输出格式编码
+	preset, style, err := resolveRender(noiseLevel)
+	if err != nil {
+		log.Error("Failed to create captcha image: %v", err)
+		return "", nil, "", err
+	}
 	var imgBuf bytes.Buffer
-	err := png.Encode(&imgBuf, img)
+	if err := encodeCaptcha(&imgBuf, code, preset, style); err != nil {
+		log.Error("Failed to create captcha image: %v", err)
+		return "", nil, "", err
+	}
+	log.Info("Encoded image to %s", style.format)
+
+	// 生成验证码ID
+	captchaID := generateCaptchaID()
+	log.Info("Generated captcha ID: %s", captchaID)
+
+	// 存储验证码信息
+	storeCaptcha(captchaID, code)
+	log.Info("Stored captcha information")
+
+	// 启动一个goroutine来删除过期的验证码
+	expireCaptchaAfter(captchaID, 60*time.Second)
+
+	return captchaID, imgBuf.Bytes(), style.format.MIMEType(), nil
+}
+
+// CaptchaStream 已生成验证码但尚未编码的图片，实现 io.WriterTo，
+// 可以不经过 base64 直接写入 http.ResponseWriter
+type CaptchaStream struct {
+	ID     string // 验证码ID
+	code   string
+	preset NoisePreset
+	style  renderStyle
+}
+
+// GetStream 生成一个验证码并返回 CaptchaStream，验证码在返回时已存储，图片在调用 WriteTo 时才绘制和编码
+//
+// 典型用法：先用 MIMEType 设置 Content-Type，再调用 WriteTo 将图片写入响应。
+func GetStream(length int, format CaptchaFormat, noiseLevel NoiseLevel) (*CaptchaStream, error) {
+	log.Info("GetStream called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
+
+	// 解析难度预设和图片配置，保证 WriteTo 时使用创建时的配置
+	preset, style, err := resolveRender(noiseLevel)
 	if err != nil {
-		log.Error("Failed to encode image to PNG: %v", err)
-		return "", "", err
+		log.Error("Failed to resolve captcha style: %v", err)
+		return nil, err
 	}
-	log.Info("Encoded image to PNG")
 
-	// 将图片编码为base64
-	imgBase64 := base64.StdEncoding.EncodeToString(imgBuf.Bytes())
-	log.Info("Encoded image to base64")
+	// 生成指定长度和格式的随机验证码
+	code := generateCaptchaCode(length, format)
+	log.Info("Generated captcha code: %s", code)
 
 	// 生成验证码ID
 	captchaID := generateCaptchaID()
@@ -52,25 +116,58 @@ func GetBase64(length int, format CaptchaFormat) (string, string, error) {
 	log.Info("Stored captcha information")
 
 	// 启动一个goroutine来删除过期的验证码
-	go func() {
-		time.Sleep(60 * time.Second)
-		deleteCaptcha(captchaID)
-		log.Info("Deleted expired captcha with ID: %s", captchaID)
-	}()
+	expireCaptchaAfter(captchaID, 60*time.Second)
 
-	return captchaID, imgBase64, nil
+	return &CaptchaStream{ID: captchaID, code: code, preset: preset, style: style}, nil
+}
+
+// Format 返回图片的输出格式
+func (s *CaptchaStream) Format() ImageFormat {
+	return s.style.format
+}
+
+// MIMEType 返回图片的 MIME 类型
+func (s *CaptchaStream) MIMEType() string {
+	return s.style.format.MIMEType()
+}
+
+// WriteTo 绘制验证码图片并编码写入 w，实现 io.WriterTo
+func (s *CaptchaStream) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	if err := encodeCaptcha(cw, s.code, s.preset, s.style); err != nil {
+		log.Error("Failed to write captcha image: %v", err)
+		return cw.n, err
+	}
+	log.Info("Wrote %d bytes of %s image for captcha ID: %s", cw.n, s.style.format, s.ID)
+	return cw.n, nil
+}
+
+// 统计写入字节数的 io.Writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // GetImage 生成一张验证码图片，并返回验证码ID和image.Image对象
-func GetImage(length int, format CaptchaFormat) (string, image.Image, error) {
-	log.Info("GetImage called with length: %d, format: %v", length, format)
+func GetImage(length int, format CaptchaFormat, noiseLevel NoiseLevel) (string, image.Image, error) {
+	log.Info("GetImage called with length: %d, format: %v, noiseLevel: %v", length, format, noiseLevel)
 
 	// 生成指定长度和格式的随机验证码
 	code := generateCaptchaCode(length, format)
 	log.Info("Generated captcha code: %s", code)
 
 	// 创建验证码图片
-	img := createCaptchaImage(code)
+	img, err := createCaptchaImage(code, noiseLevel)
+	if err != nil {
+		log.Error("Failed to create captcha image: %v", err)
+		return "", nil, err
+	}
 	log.Info("Created captcha image")
 
 	// 生成验证码ID
@@ -82,41 +179,46 @@ func GetImage(length int, format CaptchaFormat) (string, image.Image, error) {
 	log.Info("Stored captcha information")
 
 	// 启动一个goroutine来删除过期的验证码
-	go func() {
-		time.Sleep(60 * time.Second)
-		deleteCaptcha(captchaID)
-		log.Info("Deleted expired captcha with ID: %s", captchaID)
-	}()
+	expireCaptchaAfter(captchaID, 60*time.Second)
 
 	return captchaID, img, nil
 }
 
 // GetAndSave 生成一张验证码图片，并将其保存到指定路径，返回验证码ID和验证码内容
-func GetAndSave(length int, format CaptchaFormat, savePath string) (string, string, error) {
-	log.Info("GetAndSave called with length: %d, format: %v, savePath: %s", length, format, savePath)
+//
+// savePath 是相对于 SetSaveConfig 所配置目录（或文件系统）的路径，绝对路径和越出该目录的路径会被拒绝；
+// 图片先完整编码再原子地写入，不会留下写了一半的文件。
+func GetAndSave(length int, format CaptchaFormat, noiseLevel NoiseLevel, savePath string) (string, string, error) {
+	log.Info("GetAndSave called with length: %d, format: %v, noiseLevel: %v, savePath: %s", length, format, noiseLevel, savePath)
+
+	// 提前校验保存路径，避免无效请求消耗绘制开销
+	if _, err := cleanSavePath(savePath); err != nil {
+		log.Error("Rejected save path: %v", err)
+		return "", "", err
+	}
 
 	// 生成指定长度和格式的随机验证码
 	code := generateCaptchaCode(length, format)
 	log.Info("Generated captcha code: %s", code)
 
-	// 创建验证码图片
-	img := createCaptchaImage(code)
-	log.Info("Created captcha image")
-
-	// 将图片保存到指定路径
-	file, err := os.Create(savePath)
+	// 创建验证码图片，并按配置的输出格式编码
+	preset, style, err := resolveRender(noiseLevel)
 	if err != nil {
-		log.Error("Failed to create file: %v", err)
+		log.Error("Failed to create captcha image: %v", err)
+		return "", "", err
+	}
+	var imgBuf bytes.Buffer
+	if err := encodeCaptcha(&imgBuf, code, preset, style); err != nil {
+		log.Error("Failed to create captcha image: %v", err)
 		return "", "", err
 	}
-	defer file.Close()
 
-	err = png.Encode(file, img)
-	if err != nil {
-		log.Error("Failed to encode image to PNG: %v", err)
+	// 将图片保存到指定路径
+	if err := saveFile(savePath, imgBuf.Bytes()); err != nil {
+		log.Error("Failed to save image: %v", err)
 		return "", "", err
 	}
-	log.Info("Saved image to path: %s", savePath)
+	log.Info("Saved %s image to path: %s", style.format, savePath)
 
 	// 生成验证码ID
 	captchaID := generateCaptchaID()
@@ -127,11 +229,7 @@ func GetAndSave(length int, format CaptchaFormat, savePath string) (string, stri
 	log.Info("Stored captcha information")
 
 	// 启动一个goroutine来删除过期的验证码
-	go func() {
-		time.Sleep(60 * time.Second)
-		deleteCaptcha(captchaID)
-		log.Info("Deleted expired captcha with ID: %s", captchaID)
-	}()
+	expireCaptchaAfter(captchaID, 60*time.Second)
 
 	return captchaID, code, nil
 }
@@ -165,7 +263,21 @@ func Verify(captchaID, userInput string) bool {
 	}
 
 	// 验证用户输入的验证码
-	isValid := info.Code == userInput
+	var isValid bool
+	switch info.Type {
+	case TypeGrid:
+		// 选图的答案组合很少，无论对错只能验证一次，防止在有效期内穷举
+		isValid = consumeCaptcha(captchaID, info) && matchGridSelection(info.Code, userInput)
+	case TypePoW:
+		// 工作量证明验证通过后立即失效，防止同一个 nonce 被重放
+		isValid = checkPoW(info.Code, userInput, info.Difficulty) && consumeCaptcha(captchaID, info)
+	case TypeText:
+		isValid = info.Code == userInput
+	default:
+		// 手机和邮箱验证码等其他类型不能当作图片验证码使用
+		log.Warn("Captcha type %s cannot be verified by Verify, ID: %s", info.Type, captchaID)
+		return false
+	}
 	if isValid {
 		log.Info("Captcha verified successfully for ID: %s", captchaID)
 	} else {
@@ -174,26 +286,43 @@ func Verify(captchaID, userInput string) bool {
 	return isValid
 }
 
-// 验证验证码
+// 验证验证码，手机号与发送时一样先规范化，"138 0013 8000" 和 "+8613800138000" 视为同一号码
+//
+// 只能验证 SendCaptchaToPhone 发送的验证码，按用途发送的验证码需用 VerifyPhoneOTP 验证。
 func VerifyCode(phoneNumber, userInputCode string) bool {
-	captchaInfo := getCaptcha(phoneNumber)
-	if captchaInfo == nil {
-		log.Warn("Captcha not found for phone number: %s", phoneNumber)
+	return verifyPhoneCaptcha("", phoneNumber, userInputCode)
+}
+
+// 验证指定用途的手机验证码
+func verifyPhoneCaptcha(purpose OTPPurpose, phoneNumber, userInputCode string) bool {
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		log.Warn("Invalid phone number: %v", err)
+		return false
+	}
+	return verifyStoredCode(otpKey(purpose, phoneNumber), userInputCode)
+}
+
+// 验证按 key 存储的一次性验证码，过期或验证成功后删除
+func verifyStoredCode(key, userInputCode string) bool {
+	captchaInfo := getCaptcha(key)
+	if captchaInfo == nil || captchaInfo.Type != TypeOTP {
+		log.Warn("Captcha not found for key: %s", key)
 		return false
 	}
 
 	if time.Now().After(captchaInfo.ExpiresAt) {
-		log.Warn("Captcha expired for phone number: %s", phoneNumber)
-		deleteCaptcha(phoneNumber)
+		log.Warn("Captcha expired for key: %s", key)
+		deleteCaptcha(key)
 		return false
 	}
 
 	if captchaInfo.Code != userInputCode {
-		log.Warn("Captcha verification failed for phone number: %s", phoneNumber)
+		log.Warn("Captcha verification failed for key: %s", key)
 		return false
 	}
 
-	deleteCaptcha(phoneNumber)
-	log.Info("Captcha verification successful for phone number: %s", phoneNumber)
+	deleteCaptcha(key)
+	log.Info("Captcha verification successful for key: %s", key)
 	return true
 }
@@ -3,29 +3,16 @@ package captcha
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"text/template"
 	"time"
 
-	"github.com/aliyun/alibaba-cloud-sdk-go/services/dysmsapi"
 	"github.com/yowaimono/captcha/internal/log"
 )
 
-// AliyunConfig 存储阿里云短信服务配置
-type AliyunConfig struct {
-	AccessKeyID     string
-	AccessKeySecret string
-	SignName        string
-}
-
-var aliyunConfig AliyunConfig
-
-// SetAliyunConfig 设置阿里云短信服务配置
-func SetAliyunConfig(config AliyunConfig) {
-	aliyunConfig = config
-}
-
 // 生成随机验证码
 func generatePhoneCaptchaCode(length int) string {
 	rand.Seed(time.Now().UnixNano())
@@ -53,42 +40,113 @@ func renderTemplate(tpl string, data map[string]interface{}) (string, error) {
 }
 
 // 发送验证码到手机
+//
+// 通过默认短信服务商发送，templateContent 渲染后必须是 JSON 对象，其中的字段作为模板变量。
+// 手机号按 SetPhoneConfig 的默认地区规范化为 E.164 格式后发送和存储，格式不正确时返回 ErrInvalidPhoneNumber。
+//
+// Deprecated: 模板拼写错误时要到发送时才会发现，变量值中的引号也会破坏 JSON；请使用 SendCaptchaToPhoneTemplate 或 SendPhoneOTP。
 func SendCaptchaToPhone(phoneNumber string, templateCode string, templateContent string, captchaLength int) (string, error) {
-	client, err := dysmsapi.NewClientWithAccessKey("cn-hangzhou", aliyunConfig.AccessKeyID, aliyunConfig.AccessKeySecret)
-	if err != nil {
-		log.Error("Failed to create Aliyun client: %v", err)
+	return SendCaptchaToPhoneContext(context.Background(), phoneNumber, templateCode, templateContent, captchaLength)
+}
+
+// SendCaptchaToPhoneContext 与 SendCaptchaToPhone 相同，ctx 用于控制发送的超时和取消
+//
+// 配置了 SetSMSLimitConfig 时，超出发送限制会返回 *RateLimitError；按IP限制需先用 WithClientIP 记录IP。
+//
+// Deprecated: 请使用 SendCaptchaToPhoneTemplate 或 SendPhoneOTP。
+func SendCaptchaToPhoneContext(ctx context.Context, phoneNumber string, templateCode string, templateContent string, captchaLength int) (string, error) {
+	build := func(captchaCode string) (map[string]string, error) {
+		renderedTemplate, err := renderTemplate(templateContent, map[string]interface{}{"code1": captchaCode})
+		if err != nil {
+			return nil, err
+		}
+		return parseTemplateParams(renderedTemplate)
+	}
+	return sendPhoneCaptchaWith(ctx, "", PurposeConfig{}, phoneNumber, templateCode, build, captchaLength)
+}
+
+// SendCaptchaToPhoneTemplate 按结构化的短信模板发送验证码，用 VerifyCode 验证
+//
+// 验证码和模板中的变量直接作为模板变量传给服务商，不需要手写 JSON；有效期为默认的60秒。
+func SendCaptchaToPhoneTemplate(ctx context.Context, phoneNumber string, tpl SMSTemplate, captchaLength int) (string, error) {
+	if err := tpl.Validate(); err != nil {
+		log.Error("Invalid SMS template: %v", err)
 		return "", err
 	}
+	return sendPhoneCaptcha(ctx, "", PurposeConfig{}, phoneNumber, tpl, captchaLength)
+}
 
-	captchaCode := generatePhoneCaptchaCode(captchaLength)
-	data := map[string]interface{}{
-		"code1": captchaCode,
+// 按结构化模板发送手机验证码
+func sendPhoneCaptcha(ctx context.Context, purpose OTPPurpose, config PurposeConfig, phoneNumber string, tpl SMSTemplate, captchaLength int) (string, error) {
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = defaultCaptchaTTL
+	}
+	build := func(captchaCode string) (map[string]string, error) {
+		return tpl.params(captchaCode, ttl), nil
 	}
+	return sendPhoneCaptchaWith(ctx, purpose, config, phoneNumber, tpl.TemplateCode, build, captchaLength)
+}
 
-	renderedTemplate, err := renderTemplate(templateContent, data)
+// 发送手机验证码并按用途存储，purpose 为空时不区分用途；buildParams 根据验证码生成模板变量
+func sendPhoneCaptchaWith(ctx context.Context, purpose OTPPurpose, config PurposeConfig, phoneNumber string, templateCode string, buildParams func(string) (map[string]string, error), captchaLength int) (string, error) {
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		log.Error("Failed to parse phone number: %v", err)
+		return "", err
+	}
+	provider, err := lookupSMSProvider("")
+	if err != nil {
+		log.Error("Failed to get SMS provider: %v", err)
+		return "", err
+	}
+	charge, err := checkSMSLimit(ctx, phoneNumber, purpose, config.Limit)
 	if err != nil {
-		log.Error("Failed to render template: %v", err)
 		return "", err
 	}
 
-	request := dysmsapi.CreateSendSmsRequest()
-	request.Scheme = "https"
-	request.PhoneNumbers = phoneNumber
-	request.SignName = aliyunConfig.SignName
-	request.TemplateCode = templateCode
-	request.TemplateParam = renderedTemplate
+	captchaCode := generatePhoneCaptchaCode(captchaLength)
+	params, err := buildParams(captchaCode)
+	if err != nil {
+		log.Error("Failed to build SMS template params: %v", err)
+		charge.rollback(ctx)
+		return "", err
+	}
 
-	response, err := client.SendSms(request)
+	messageID, err := provider.Send(ctx, phoneNumber, templateCode, params)
 	if err != nil {
+		// 服务商明确拒绝的短信不占用冷却时间和配额，用户可以立即重试；
+		// 取消或超时时短信可能仍会送达，计数保留，防止中断请求绕过限制
 		log.Error("Failed to send SMS: %v", err)
+		if !deliveryUnknown(err) {
+			charge.rollback(ctx)
+		}
 		return "", err
 	}
+	log.Info("Sent captcha SMS to %s, purpose: %q, message ID: %s", phoneNumber, purpose, messageID)
 
-	if response.Code != "OK" {
-		log.Error("Failed to send SMS: %s", response.Message)
-		return "", fmt.Errorf("failed to send SMS: %s", response.Message)
+	info := &CaptchaInfo{Type: TypeOTP, Code: captchaCode}
+	if config.TTL > 0 {
+		info.ExpiresAt = time.Now().Add(config.TTL)
 	}
-
-	storeCaptcha(phoneNumber, captchaCode)
+	storeCaptchaInfo(otpKey(purpose, phoneNumber), info)
 	return captchaCode, nil
-}
\ No newline at end of file
+}
+
+// 将渲染后的 JSON 对象解析为模板变量，字符串取其内容，其他类型保留 JSON 原文
+func parseTemplateParams(rendered string) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(rendered), &raw); err != nil {
+		return nil, fmt.Errorf("SMS template must render to a JSON object: %w", err)
+	}
+	params := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			params[k] = s
+		} else {
+			params[k] = string(v)
+		}
+	}
+	return params, nil
+}
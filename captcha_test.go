@@ -7,13 +7,14 @@ import (
 )
 
 func TestGetOneAndVerify(t *testing.T) {
-	captchaID, _, err := GetOne(6, Mixed)
+	captchaID, _, err := GetOne(6, Mixed, Mid)
 	if err != nil {
 		t.Fatalf("生成验证码失败: %v", err)
 	}
+	code := getCaptcha(captchaID).Code
 
 	// 验证码应该有效
-	if !Verify(captchaID, "123456") {
+	if !Verify(captchaID, code) {
 		t.Fatalf("验证码验证失败")
 	}
 
@@ -21,7 +22,7 @@ func TestGetOneAndVerify(t *testing.T) {
 	time.Sleep(61 * time.Second)
 
 	// 验证码应该过期
-	if Verify(captchaID, "123456") {
+	if Verify(captchaID, code) {
 		t.Fatalf("验证码未过期")
 	}
 }
\ No newline at end of file
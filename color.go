@@ -0,0 +1,97 @@
+package captcha
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// 颜色随机程度为0时使用的字符颜色
+var baseGlyphColor = color.RGBA{30, 30, 60, 255}
+
+// 按 WCAG 定义计算颜色的相对亮度，范围 [0, 1]
+func relativeLuminance(c color.RGBA) float64 {
+	channel := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// 按 WCAG 定义计算两种颜色的对比度，范围 [1, 21]
+func contrastRatio(a, b color.RGBA) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// 为字符选择颜色，拒绝与背景对比度低于最小值的颜色
+//
+// 有调色板时从满足对比度的调色板颜色中随机选择；否则按颜色随机程度随机生成，
+// 多次尝试仍不满足时调整最后一次生成的颜色。
+func pickGlyphColor(style renderStyle, variance float64, bg color.RGBA) color.RGBA {
+	if len(style.palette) > 0 {
+		candidates := make([]color.RGBA, 0, len(style.palette))
+		for _, col := range style.palette {
+			if contrastRatio(col, bg) >= style.minContrast {
+				candidates = append(candidates, col)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates[rand.Intn(len(candidates))]
+		}
+		return ensureContrast(style.palette[rand.Intn(len(style.palette))], bg, style.minContrast)
+	}
+
+	var col color.RGBA
+	for i := 0; i < 10; i++ {
+		// 按颜色随机程度在基准深色和随机色之间插值
+		col = color.RGBA{
+			uint8(float64(baseGlyphColor.R)*(1-variance) + float64(rand.Intn(256))*variance),
+			uint8(float64(baseGlyphColor.G)*(1-variance) + float64(rand.Intn(256))*variance),
+			uint8(float64(baseGlyphColor.B)*(1-variance) + float64(rand.Intn(256))*variance),
+			255,
+		}
+		if contrastRatio(col, bg) >= style.minContrast {
+			return col
+		}
+	}
+	return ensureContrast(col, bg, style.minContrast)
+}
+
+// 将预乘的半透明颜色叠加到不透明的底色上
+func flattenColor(c, under color.RGBA) color.RGBA {
+	inv := 255 - uint32(c.A)
+	return color.RGBA{
+		uint8(uint32(c.R) + uint32(under.R)*inv/255),
+		uint8(uint32(c.G) + uint32(under.G)*inv/255),
+		uint8(uint32(c.B) + uint32(under.B)*inv/255),
+		255,
+	}
+}
+
+// 调整颜色使其与背景的对比度不低于 minRatio
+//
+// 背景较亮时逐步向黑色混合，较暗时逐步向白色混合，尽量保留原有色相。
+func ensureContrast(col, bg color.RGBA, minRatio float64) color.RGBA {
+	if minRatio <= 1 || contrastRatio(col, bg) >= minRatio {
+		return col
+	}
+
+	target := color.RGBA{0, 0, 0, 255}
+	if contrastRatio(target, bg) < contrastRatio(color.RGBA{255, 255, 255, 255}, bg) {
+		target = color.RGBA{255, 255, 255, 255}
+	}
+	for t := 0.1; t < 1; t += 0.1 {
+		mixed := lerpColor(col, target, t)
+		if contrastRatio(mixed, bg) >= minRatio {
+			return mixed
+		}
+	}
+	return target
+}
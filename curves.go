@@ -0,0 +1,170 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// CurveOptions 干扰曲线配置
+type CurveOptions struct {
+	Count         int     // 随机贝塞尔曲线的数量
+	MinWidth      float64 // 最小线宽（像素）
+	MaxWidth      float64 // 最大线宽（像素）
+	StrikeThrough bool    // 是否额外绘制一条穿过所有字符的删除线
+}
+
+// SetCurveOptions 设置指定难度级别预设中的干扰曲线配置
+func SetCurveOptions(level NoiseLevel, options CurveOptions) {
+	updateNoisePreset(level, func(preset *NoisePreset) {
+		preset.Curves = options
+	})
+	log.Info("Set curve options for noise level %v: %+v", level, options)
+}
+
+type point struct {
+	x, y float64
+}
+
+// 一条干扰曲线展开后的折线，线宽从 w0 线性变化到 w1
+type curveStroke struct {
+	pts    []point
+	w0, w1 float64
+	col    color.RGBA
+}
+
+// 线宽按缩放倍数放大
+func scaledCurves(options CurveOptions, scale float64) CurveOptions {
+	options.MinWidth *= scale
+	options.MaxWidth *= scale
+	return options
+}
+
+// 生成干扰曲线，曲线颜色取自字符颜色
+func curveStrokes(bounds image.Rectangle, options CurveOptions, slots []glyphSlot, baseline int, palette []color.RGBA) []curveStroke {
+	if len(palette) == 0 {
+		palette = []color.RGBA{{0, 0, 0, 255}}
+	}
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	randomStroke := func(pts []point) curveStroke {
+		return curveStroke{pts, randomWidth(options), randomWidth(options), palette[rand.Intn(len(palette))]}
+	}
+	var strokes []curveStroke
+	randomPoint := func(xMin, xMax float64) point {
+		return point{xMin + rand.Float64()*(xMax-xMin), rand.Float64() * height}
+	}
+
+	// 随机三次贝塞尔曲线，从左侧延伸到右侧
+	for i := 0; i < options.Count; i++ {
+		p0 := randomPoint(0, width/4)
+		p1 := randomPoint(0, width)
+		p2 := randomPoint(0, width)
+		p3 := randomPoint(width*3/4, width)
+		strokes = append(strokes, randomStroke(flattenCubic(nil, p0, p1, p2, p3, 32)))
+	}
+
+	// 穿过每个字符中部的删除线，用 Catmull-Rom 样条连接各字符中心
+	if options.StrikeThrough && len(slots) > 0 {
+		pts := make([]point, 0, len(slots)+2)
+		jitter := func() float64 { return (rand.Float64() - 0.5) * height / 6 }
+		for _, slot := range slots {
+			mid := float64(baseline) - float64(slot.face.Metrics().XHeight.Round())/2
+			pts = append(pts, point{float64(slot.x) + float64(slot.advance)/2, mid + jitter()})
+		}
+		pts = append([]point{{0, pts[0].y + jitter()}}, pts...)
+		pts = append(pts, point{width, pts[len(pts)-1].y + jitter()})
+
+		strokes = append(strokes, randomStroke(catmullRom(pts, 12)))
+	}
+	return strokes
+}
+
+func randomWidth(options CurveOptions) float64 {
+	lo, hi := options.MinWidth, math.Max(options.MinWidth, options.MaxWidth)
+	return math.Max(0.5, lo+rand.Float64()*(hi-lo))
+}
+
+// 将三次贝塞尔曲线展开为折线，追加到 pts 后返回
+func flattenCubic(pts []point, p0, p1, p2, p3 point, steps int) []point {
+	if len(pts) == 0 {
+		pts = append(pts, p0)
+	}
+	for i := 1; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		pts = append(pts, point{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+	return pts
+}
+
+// 将经过所有点的 Catmull-Rom 样条转换为三次贝塞尔曲线并展开为折线
+func catmullRom(pts []point, steps int) []point {
+	var out []point
+	for i := 0; i+1 < len(pts); i++ {
+		p0, p1, p2 := pts[max(0, i-1)], pts[i], pts[i+1]
+		p3 := pts[min(len(pts)-1, i+2)]
+		c1 := point{p1.x + (p2.x-p0.x)/6, p1.y + (p2.y-p0.y)/6}
+		c2 := point{p2.x - (p3.x-p1.x)/6, p2.y - (p3.y-p1.y)/6}
+		out = flattenCubic(out, p1, c1, c2, p2, steps)
+	}
+	return out
+}
+
+// 以抗锯齿方式描绘折线，线宽从 w0 线性变化到 w1
+//
+// 先在遮罩中按像素到线段的距离取各线段覆盖率的最大值，再一次性混合到图片上，
+// 避免线段连接处重复混合产生深色接缝。
+func strokePolyline(img *image.RGBA, pts []point, w0, w1 float64, col color.RGBA) {
+	if len(pts) < 2 {
+		return
+	}
+	mask := newMask(img.Rect.Dx(), img.Rect.Dy())
+	defer releaseMask(mask)
+
+	for i := 0; i+1 < len(pts); i++ {
+		a, b := pts[i], pts[i+1]
+		t := float64(i) / float64(len(pts)-1)
+		half := (w0 + (w1-w0)*t) / 2
+
+		x0 := max(0, int(math.Floor(math.Min(a.x, b.x)-half-1)))
+		x1 := min(mask.Rect.Dx()-1, int(math.Ceil(math.Max(a.x, b.x)+half+1)))
+		y0 := max(0, int(math.Floor(math.Min(a.y, b.y)-half-1)))
+		y1 := min(mask.Rect.Dy()-1, int(math.Ceil(math.Max(a.y, b.y)+half+1)))
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				d := distToSegment(point{float64(x) + 0.5, float64(y) + 0.5}, a, b)
+				coverage := max(0, min(1, half+0.5-d))
+				if v := uint8(coverage * 255); v > mask.Pix[y*mask.Stride+x] {
+					mask.Pix[y*mask.Stride+x] = v
+				}
+			}
+		}
+	}
+
+	for y := 0; y < mask.Rect.Dy(); y++ {
+		for x, a := range mask.Pix[y*mask.Stride : y*mask.Stride+mask.Rect.Dx()] {
+			if a != 0 {
+				blendPixel(img, x, y, col, a)
+			}
+		}
+	}
+}
+
+// 点到线段的距离
+func distToSegment(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	lenSq := dx*dx + dy*dy
+	t := 0.0
+	if lenSq > 0 {
+		t = max(0, min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/lenSq))
+	}
+	ex, ey := p.x-(a.x+t*dx), p.y-(a.y+t*dy)
+	return math.Sqrt(ex*ex + ey*ey)
+}
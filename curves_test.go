@@ -0,0 +1,33 @@
+package captcha
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestCatmullRomPassesThroughPoints(t *testing.T) {
+	pts := []point{{0, 10}, {20, 5}, {40, 15}, {60, 10}}
+	out := catmullRom(pts, 8)
+
+	// 每段展开为8个点，第 i 个控制点位于折线的第 8*i 个点
+	for i, p := range pts {
+		got := out[8*i]
+		if math.Abs(got.x-p.x) > 1e-9 || math.Abs(got.y-p.y) > 1e-9 {
+			t.Fatalf("样条应经过 %v，实际为 %v", p, got)
+		}
+	}
+}
+
+func TestStrokePolylineAntiAliased(t *testing.T) {
+	img := newCanvas(20, 10)
+	fillRGBA(img, color.RGBA{255, 255, 255, 255})
+	strokePolyline(img, []point{{0, 5}, {20, 5}}, 2, 2, color.RGBA{0, 0, 0, 255})
+
+	if got := img.RGBAAt(10, 4); got.R != 0 {
+		t.Fatalf("线条中心应为黑色，实际为 %v", got)
+	}
+	if got := img.RGBAAt(10, 1); got.R != 255 {
+		t.Fatalf("远离线条的像素应保持白色，实际为 %v", got)
+	}
+}
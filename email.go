@@ -0,0 +1,406 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// SMTPSecurity SMTP 连接的加密方式
+type SMTPSecurity string
+
+const (
+	SMTPStartTLS SMTPSecurity = "starttls" // 明文连接后用 STARTTLS 升级，服务器不支持时拒绝发送（默认，通常为587端口）
+	SMTPTLS      SMTPSecurity = "tls"      // 直接建立 TLS 连接（通常为465端口）
+	SMTPNone     SMTPSecurity = "none"     // 不加密，只应用于本机或内网的中继
+)
+
+// EmailConfig 邮箱验证码配置
+//
+// Subject 和 Text 是 text/template 模板，HTML 是 html/template 模板，可使用 .Code（验证码）、
+// .Minutes（有效期分钟数）和 .Email（收件地址）。
+type EmailConfig struct {
+	Host      string       // SMTP 服务器地址
+	Port      int          // SMTP 端口，默认 STARTTLS 为587，TLS 为465，不加密为25
+	Username  string       // 登录用户名，为空时不登录
+	Password  string       // 登录密码
+	Security  SMTPSecurity // 加密方式，默认 SMTPStartTLS
+	TLSConfig *tls.Config  // 自定义 TLS 配置，默认校验服务器证书
+	Timeout   time.Duration
+
+	From    string // 发件地址，可带名称，如 "Example <no-reply@example.com>"
+	Subject string // 邮件标题模板
+	Text    string // 纯文本正文模板
+	HTML    string // HTML 正文模板，为空时只发送纯文本
+
+	TTL   time.Duration    // 验证码有效期，默认5分钟
+	Limit *SendLimitConfig // 发送限制，接收方为收件地址；为 nil 时不限制
+}
+
+const (
+	defaultEmailSubject = "Your verification code: {{.Code}}"
+	defaultEmailText    = "Your verification code is {{.Code}}. It expires in {{.Minutes}} minutes.\r\nIf you did not request this code, please ignore this email."
+)
+
+// 解析后的邮件发送配置
+type emailSender struct {
+	config  EmailConfig
+	from    *mail.Address
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// 模板中可用的数据
+type emailData struct {
+	Code    string
+	Minutes int
+	Email   string
+}
+
+var (
+	// ErrEmailNotConfigured 表示尚未调用 SetEmailConfig
+	ErrEmailNotConfigured = errors.New("email OTP is not configured")
+	// ErrInvalidEmail 表示邮箱地址格式不正确
+	ErrInvalidEmail = errors.New("invalid email address")
+
+	emailSenderConfig *emailSender
+	emailLock         sync.RWMutex
+)
+
+// SetEmailConfig 设置邮箱验证码的 SMTP 服务器和邮件模板，模板在此时解析，配置错误会立即返回
+func SetEmailConfig(config EmailConfig) error {
+	sender, err := newEmailSender(config)
+	if err != nil {
+		log.Error("Failed to set email config: %v", err)
+		return err
+	}
+
+	emailLock.Lock()
+	defer emailLock.Unlock()
+	emailSenderConfig = sender
+	log.Info("Set email config: %s:%d, security=%s, from=%s", sender.config.Host, sender.config.Port, sender.config.Security, sender.from)
+	return nil
+}
+
+func newEmailSender(config EmailConfig) (*emailSender, error) {
+	if config.Host == "" {
+		return nil, errors.New("email OTP requires SMTP host")
+	}
+	if config.Security == "" {
+		config.Security = SMTPStartTLS
+	}
+	if config.Port == 0 {
+		switch config.Security {
+		case SMTPStartTLS:
+			config.Port = 587
+		case SMTPTLS:
+			config.Port = 465
+		case SMTPNone:
+			config.Port = 25
+		}
+	}
+	switch config.Security {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP security: %q", config.Security)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	if config.Subject == "" {
+		config.Subject = defaultEmailSubject
+	}
+	if config.Text == "" {
+		config.Text = defaultEmailText
+	}
+	if config.Limit != nil {
+		limit := *config.Limit
+		if limit.Store == nil {
+			limit.Store = NewMemoryCounterStore()
+		}
+		config.Limit = &limit
+	}
+
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email sender %q: %w", config.From, err)
+	}
+	sender := &emailSender{config: config, from: from}
+	if sender.subject, err = template.New("subject").Option("missingkey=error").Parse(config.Subject); err != nil {
+		return nil, fmt.Errorf("parse email subject template: %w", err)
+	}
+	if sender.text, err = template.New("text").Option("missingkey=error").Parse(config.Text); err != nil {
+		return nil, fmt.Errorf("parse email text template: %w", err)
+	}
+	if config.HTML != "" {
+		if sender.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(config.HTML); err != nil {
+			return nil, fmt.Errorf("parse email HTML template: %w", err)
+		}
+	}
+	// 用示例数据渲染一次，提前发现模板中引用了不存在的字段等错误
+	if _, err := sender.message(emailData{Code: "000000", Minutes: 5, Email: "user@example.com"}); err != nil {
+		return nil, err
+	}
+	return sender, nil
+}
+
+// NormalizeEmail 解析并规范化邮箱地址（去掉名称，转为小写），用作验证码的存储键
+func NormalizeEmail(raw string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(raw))
+	if err != nil || !strings.Contains(addr.Address, "@") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, raw)
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// SendEmailOTP 发送数字验证码到邮箱，返回验证码
+//
+// 验证码的存储方式与手机验证码相同，发送限制由 EmailConfig.Limit 设置；按IP限制需先用 WithClientIP 记录IP。
+func SendEmailOTP(ctx context.Context, email string, captchaLength int) (string, error) {
+	emailLock.RLock()
+	sender := emailSenderConfig
+	emailLock.RUnlock()
+	if sender == nil {
+		return "", ErrEmailNotConfigured
+	}
+
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		log.Error("Failed to parse email: %v", err)
+		return "", err
+	}
+	charge := &limitCharge{}
+	if sender.config.Limit != nil {
+		if err := applySendLimit(ctx, *sender.config.Limit, "email:", "email", email, charge); err != nil {
+			charge.rollback(ctx)
+			return "", err
+		}
+	}
+
+	code := generatePhoneCaptchaCode(captchaLength)
+	msg, err := sender.message(emailData{Code: code, Minutes: int((sender.config.TTL + time.Minute - 1) / time.Minute), Email: email})
+	if err != nil {
+		log.Error("Failed to render email: %v", err)
+		charge.rollback(ctx)
+		return "", err
+	}
+	if err := sender.send(ctx, email, msg); err != nil {
+		log.Error("Failed to send email: %v", err)
+		if !deliveryUnknown(err) {
+			charge.rollback(ctx)
+		}
+		return "", err
+	}
+	log.Info("Sent captcha email to %s", email)
+
+	storeCaptchaInfo(emailKey(email), &CaptchaInfo{
+		Type:      TypeOTP,
+		Code:      code,
+		ExpiresAt: time.Now().Add(sender.config.TTL),
+	})
+	return code, nil
+}
+
+// VerifyEmailOTP 验证邮箱验证码，验证成功后验证码失效
+func VerifyEmailOTP(email, userInputCode string) bool {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		log.Warn("Invalid email: %v", err)
+		return false
+	}
+	return verifyStoredCode(emailKey(email), userInputCode)
+}
+
+func emailKey(email string) string {
+	return "email:" + email
+}
+
+// 生成邮件，有 HTML 模板时为 multipart/alternative，正文使用 quoted-printable 编码
+func (s *emailSender) message(data emailData) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+	if err := s.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("render email subject: %w", err)
+	}
+	if err := s.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("render email text: %w", err)
+	}
+	if s.html != nil {
+		if err := s.html.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("render email HTML: %w", err)
+		}
+	}
+
+	var msg bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", s.from.String())
+	header.Set("To", data.Email)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", "<"+generateCaptchaID()+"@"+s.fromDomain()+">")
+	header.Set("MIME-Version", "1.0")
+
+	if s.html == nil {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&msg, header)
+		if err := writeQuotedPrintable(&msg, text.Bytes()); err != nil {
+			return nil, err
+		}
+		return msg.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&msg, header)
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func (s *emailSender) fromDomain() string {
+	if i := strings.LastIndex(s.from.Address, "@"); i >= 0 {
+		return s.from.Address[i+1:]
+	}
+	return "localhost"
+}
+
+// 按固定顺序写入邮件头
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	for _, k := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(k); v != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, content []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(content); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// 通过 SMTP 发送邮件，ctx 取消或超时时关闭连接并返回 ctx.Err()
+func (s *emailSender) send(ctx context.Context, to string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	if err := s.deliver(ctx, to, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *emailSender) deliver(ctx context.Context, to string, msg []byte) error {
+	config := s.config
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := config.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: config.Host}
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if config.Security == SMTPTLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if config.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,264 @@
+package captcha
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用 SMTP 服务器收到的邮件
+type smtpMessage struct {
+	from, to string
+	tls      bool
+	auth     string
+	data     string
+}
+
+// 启动一个进程内的最小 SMTP 服务器，serverTLS 不为 nil 时支持 STARTTLS
+func startSMTPServer(t *testing.T, serverTLS *tls.Config) (int, <-chan smtpMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan smtpMessage, 4)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, serverTLS, messages)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, messages
+}
+
+func serveSMTP(conn net.Conn, serverTLS *tls.Config, messages chan<- smtpMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+	var msg smtpMessage
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO":
+			if serverTLS != nil && !msg.tls {
+				reply("250-localhost")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-localhost")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, serverTLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, msg.tls = tlsConn, bufio.NewReader(tlsConn), true
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			msg.auth = string(decoded)
+			reply("235 ok")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			msg.to = strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			messages <- msg
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// 生成 127.0.0.1 的自签名证书，返回服务端和客户端的 TLS 配置
+func selfSignedTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		&tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+// 关闭邮箱验证码配置，测试结束时恢复
+func resetEmailConfig(t *testing.T) {
+	emailLock.Lock()
+	saved := emailSenderConfig
+	emailSenderConfig = nil
+	emailLock.Unlock()
+	t.Cleanup(func() {
+		emailLock.Lock()
+		emailSenderConfig = saved
+		emailLock.Unlock()
+	})
+}
+
+func TestSendEmailOTPStartTLS(t *testing.T) {
+	resetEmailConfig(t)
+	serverTLS, clientTLS := selfSignedTLS(t)
+	port, messages := startSMTPServer(t, serverTLS)
+
+	if _, err := SendEmailOTP(context.Background(), "user@example.com", 6); !errors.Is(err, ErrEmailNotConfigured) {
+		t.Fatalf("未配置时应返回 ErrEmailNotConfigured，实际为 %v", err)
+	}
+	err := SetEmailConfig(EmailConfig{
+		Host:      "127.0.0.1",
+		Port:      port,
+		Username:  "mailer",
+		Password:  "secret",
+		TLSConfig: clientTLS,
+		From:      "验证码 <no-reply@example.com>",
+		Subject:   "验证码 {{.Code}}",
+		HTML:      `<p>Code: <b>{{.Code}}</b> for {{.Email}}, valid {{.Minutes}} min</p>`,
+		TTL:       90 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("设置邮箱配置失败: %v", err)
+	}
+
+	code, err := SendEmailOTP(context.Background(), "User@Example.com", 6)
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	msg := <-messages
+	if !msg.tls || msg.auth != "\x00mailer\x00secret" || msg.from != "no-reply@example.com" || msg.to != "user@example.com" {
+		t.Fatalf("SMTP 会话错误: tls=%v auth=%q from=%s to=%s", msg.tls, msg.auth, msg.from, msg.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "验证码 "+code {
+		t.Fatalf("邮件标题错误: %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		parts = append(parts, part.Header.Get("Content-Type")+"\n"+string(body))
+	}
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "text/plain") || !strings.Contains(parts[0], code) ||
+		!strings.HasPrefix(parts[1], "text/html") || !strings.Contains(parts[1], "<b>"+code+"</b>") || !strings.Contains(parts[1], "valid 2 min") {
+		t.Fatalf("邮件正文错误: %q", parts)
+	}
+
+	if VerifyEmailOTP("user@example.com", "wrong") || !VerifyEmailOTP("USER@example.com", code) {
+		t.Fatalf("邮箱验证码验证结果错误")
+	}
+	if VerifyEmailOTP("user@example.com", code) {
+		t.Fatalf("验证码验证成功后应失效")
+	}
+}
+
+func TestSendEmailOTPRequiresStartTLS(t *testing.T) {
+	resetEmailConfig(t)
+	port, messages := startSMTPServer(t, nil)
+
+	SetEmailConfig(EmailConfig{Host: "127.0.0.1", Port: port, From: "no-reply@example.com"})
+	if _, err := SendEmailOTP(context.Background(), "user@example.com", 6); err == nil {
+		t.Fatalf("服务器不支持 STARTTLS 时应拒绝发送")
+	}
+
+	// 明确不加密时可以发送，发送限制与手机验证码相同
+	SetEmailConfig(EmailConfig{Host: "127.0.0.1", Port: port, Security: SMTPNone, From: "no-reply@example.com",
+		Limit: &SendLimitConfig{Cooldown: time.Minute, RecipientHourly: 1}})
+	if _, err := SendEmailOTP(context.Background(), "user@example.com", 6); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if msg := <-messages; msg.tls || !strings.Contains(msg.data, "Content-Type: text/plain; charset=utf-8") {
+		t.Fatalf("应发送不加密的纯文本邮件: %+v", msg)
+	}
+	_, err := SendEmailOTP(context.Background(), "user@example.com", 6)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != "cooldown" {
+		t.Fatalf("冷却期内应返回 cooldown 限制，实际为 %v", err)
+	}
+}
+
+func TestEmailConfigValidation(t *testing.T) {
+	for i, config := range []EmailConfig{
+		{From: "no-reply@example.com"},
+		{Host: "smtp.example.com", From: "not an address"},
+		{Host: "smtp.example.com", From: "no-reply@example.com", Text: "{{.Cod}}"},
+		{Host: "smtp.example.com", From: "no-reply@example.com", HTML: "{{.Code"},
+		{Host: "smtp.example.com", From: "no-reply@example.com", Security: "ssl"},
+	} {
+		if err := SetEmailConfig(config); err == nil {
+			t.Errorf("第 %d 个配置应返回错误", i)
+		}
+	}
+	if _, err := NormalizeEmail("not-an-email"); !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("无效邮箱应返回 ErrInvalidEmail，实际为 %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package captcha
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// ImageFormat 验证码图片的输出格式
+type ImageFormat string
+
+const (
+	FormatPNG  ImageFormat = "png"  // 无损 PNG，默认格式，支持透明背景
+	FormatJPEG ImageFormat = "jpeg" // 有损 JPEG，体积最小，质量由 ImageConfig.Quality 控制
+	FormatGIF  ImageFormat = "gif"  // 256 色 GIF，兼容老旧客户端
+	FormatWebP ImageFormat = "webp" // 无损 WebP，支持透明背景
+	FormatSVG  ImageFormat = "svg"  // 矢量 SVG，字符、噪点和干扰线输出为路径
+)
+
+// MIMEType 返回格式对应的 MIME 类型，可用于 HTTP 响应的 Content-Type
+func (f ImageFormat) MIMEType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatGIF:
+		return "image/gif"
+	case FormatWebP:
+		return "image/webp"
+	case FormatSVG:
+		return "image/svg+xml"
+	default:
+		return "image/png"
+	}
+}
+
+// Extension 返回格式对应的文件扩展名（含点号）
+func (f ImageFormat) Extension() string {
+	switch f {
+	case FormatJPEG:
+		return ".jpg"
+	case "":
+		return ".png"
+	default:
+		return "." + string(f)
+	}
+}
+
+// ParseImageFormat 解析输出格式名称（不区分大小写，接受 jpg），空字符串视为 PNG
+func ParseImageFormat(s string) (ImageFormat, error) {
+	switch f := ImageFormat(strings.ToLower(s)); f {
+	case "":
+		return FormatPNG, nil
+	case "jpg":
+		return FormatJPEG, nil
+	case FormatPNG, FormatJPEG, FormatGIF, FormatWebP, FormatSVG:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown image format: %q", s)
+	}
+}
+
+// 查找难度预设并按当前图片配置解析绘制样式
+func resolveRender(noiseLevel NoiseLevel) (NoisePreset, renderStyle, error) {
+	preset, err := lookupNoisePreset(noiseLevel)
+	if err != nil {
+		return NoisePreset{}, renderStyle{}, err
+	}
+	style, err := currentRenderStyle()
+	if err != nil {
+		return NoisePreset{}, renderStyle{}, err
+	}
+	return preset, style, nil
+}
+
+// 按难度预设和绘制样式绘制验证码并编码写入 w
+func encodeCaptcha(w io.Writer, code string, preset NoisePreset, style renderStyle) error {
+	if style.format == FormatSVG {
+		return renderCaptchaSVG(w, code, preset, style)
+	}
+
+	img, err := renderCaptcha(code, preset, style)
+	if err != nil {
+		return err
+	}
+	defer releaseCanvas(img)
+	return encodeImage(w, img, style)
+}
+
+// 将光栅图片按样式中的格式编码，编码过程中可能修改 img
+func encodeImage(w io.Writer, img *image.RGBA, style renderStyle) error {
+	switch style.format {
+	case FormatJPEG:
+		return jpeg.Encode(w, flattenImage(img, style), &jpeg.Options{Quality: style.quality})
+	case FormatGIF:
+		return gif.Encode(w, flattenImage(img, style), &gif.Options{NumColors: 256})
+	case FormatWebP:
+		return encodeWebP(w, img)
+	default:
+		return pngEncoder.Encode(w, img)
+	}
+}
+
+// JPEG 和 GIF 不支持半透明，透明背景时将图片合成到页面背景色上
+func flattenImage(img *image.RGBA, style renderStyle) *image.RGBA {
+	if style.background != nil {
+		return img
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+4 : i+4]
+		c := flattenColor(color.RGBA{p[0], p[1], p[2], p[3]}, style.pageColor)
+		p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
@@ -0,0 +1,217 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeFormats(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	decoders := map[ImageFormat]func(io.Reader) (image.Image, error){
+		FormatPNG:  png.Decode,
+		FormatJPEG: jpeg.Decode,
+		FormatGIF:  gif.Decode,
+		FormatWebP: webp.Decode,
+	}
+	for format, decode := range decoders {
+		SetImageConfig(ImageConfig{Format: format, Transparent: true})
+		_, data, mimeType, err := GetBytes(6, Mixed, Mid)
+		if err != nil || mimeType != format.MIMEType() {
+			t.Fatalf("%s 编码失败: %q, %v", format, mimeType, err)
+		}
+		img, err := decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s 解码失败: %v", format, err)
+		}
+		if img.Bounds().Dx() < 60 || img.Bounds().Dy() < minHeight {
+			t.Fatalf("%s 图片尺寸异常: %v", format, img.Bounds())
+		}
+	}
+}
+
+func TestSVGPaint(t *testing.T) {
+	cases := []struct {
+		c    color.RGBA
+		want string
+	}{
+		{color.RGBA{31, 58, 147, 255}, ` fill="#1f3a93"`},
+		// 预乘颜色转换为非预乘的颜色和透明度
+		{color.RGBA{64, 32, 16, 128}, ` fill="#7f3f1f" fill-opacity="0.5"`},
+		{color.RGBA{0, 0, 0, 0}, ` fill="none"`},
+	}
+	for _, c := range cases {
+		if got := svgPaint("fill", c.c); got != c.want {
+			t.Errorf("svgPaint(%v) = %s, want %s", c.c, got, c.want)
+		}
+		// 与标准库的非预乘转换结果一致
+		if c.c.A != 0 {
+			n := color.NRGBAModel.Convert(c.c).(color.NRGBA)
+			if !strings.Contains(svgPaint("fill", c.c), fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)) {
+				t.Errorf("svgPaint(%v) 与 color.NRGBA %v 不一致", c.c, n)
+			}
+		}
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	SetImageConfig(ImageConfig{Format: FormatSVG})
+	stream, err := GetStream(4, Mixed, Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if n, err := stream.WriteTo(&buf); err != nil || n != int64(buf.Len()) {
+		t.Fatalf("SVG 编码失败: %d, %v", n, err)
+	}
+	if stream.MIMEType() != "image/svg+xml" {
+		t.Fatalf("MIME 类型错误: %s", stream.MIMEType())
+	}
+
+	var doc struct {
+		Width int `xml:"width,attr"`
+		Paths []struct {
+			D         string `xml:"d,attr"`
+			Transform string `xml:"transform,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("SVG 格式错误: %v", err)
+	}
+	if doc.Width == 0 || len(doc.Paths) != 4 {
+		t.Fatalf("Simple 级别应只有4个字符路径，实际为 %d 个", len(doc.Paths))
+	}
+	for _, p := range doc.Paths {
+		// 变形直接计算到坐标中，路径只包含 M、C、Z 命令，不能从命令序列识别字形
+		if p.D == "" || p.Transform != "" || strings.Trim(p.D, "MCZ0123456789.- ") != "" {
+			t.Fatalf("字符路径应为变形后的三次曲线: %+v", p)
+		}
+	}
+}
+
+func TestSVGGlyphPathRandomized(t *testing.T) {
+	style, err := currentRenderStyle()
+	if err != nil {
+		t.Fatalf("解析绘制样式失败: %v", err)
+	}
+	layout, err := layoutCaptcha("A", style)
+	if err != nil {
+		t.Fatalf("排版失败: %v", err)
+	}
+	defer layout.release()
+
+	// 同一个字符每次输出的路径都不同，无法与字体轮廓或之前的输出逐一比对
+	var sfntBuf sfnt.Buffer
+	slot := layout.slots[0]
+	seen := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		warp := newSVGGlyphWarp(float64(slot.x), float64(layout.baseline), 0, svgWave{}, 0.012*layout.faces.size)
+		d, err := glyphPath(&sfntBuf, slot, layout.baseline, layout.faces.size, warp)
+		if err != nil || d == "" {
+			t.Fatalf("生成字形路径失败: %q, %v", d, err)
+		}
+		if seen[d] {
+			t.Fatalf("同一字符的路径不应重复: %s", d)
+		}
+		seen[d] = true
+	}
+}
+
+func TestWebPLossless(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 37, 11))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(rand.Intn(256))
+	}
+	// 加入大段重复像素，覆盖后向引用
+	for x := 0; x < 37; x++ {
+		src.SetNRGBA(x, 5, color.NRGBA{10, 20, 30, 255})
+		src.SetNRGBA(x, 6, color.NRGBA{10, 20, 30, 255})
+	}
+
+	var buf bytes.Buffer
+	if err := encodeWebP(&buf, src); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	got, err := webp.Decode(&buf)
+	if err != nil {
+		t.Fatalf("解码失败: %v", err)
+	}
+	for y := 0; y < 11; y++ {
+		for x := 0; x < 37; x++ {
+			if want, have := src.NRGBAAt(x, y), color.NRGBAModel.Convert(got.At(x, y)); want != have {
+				t.Fatalf("(%d, %d) 像素不一致: 期望 %v，实际 %v", x, y, want, have)
+			}
+		}
+	}
+}
+
+func TestWebPPixelsFastPath(t *testing.T) {
+	// 非零起点的子图，像素为合法的预乘颜色（分量不大于 alpha）
+	img := image.NewRGBA(image.Rect(0, 0, 20, 9)).SubImage(image.Rect(3, 2, 17, 9)).(*image.RGBA)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := uint8(rand.Intn(256))
+			if x%3 == 0 {
+				a = 255
+			}
+			img.SetRGBA(x, y, color.RGBA{uint8(rand.Intn(int(a) + 1)), uint8(rand.Intn(int(a) + 1)), uint8(rand.Intn(int(a) + 1)), a})
+		}
+	}
+
+	fast, fastAlpha := webpPixels(img)
+	generic, genericAlpha := webpPixels(struct{ image.Image }{img})
+	if fastAlpha != genericAlpha || len(fast) != len(generic) {
+		t.Fatalf("快速路径结果与逐像素转换不一致")
+	}
+	for i := range fast {
+		if fast[i] != generic[i] {
+			t.Fatalf("第 %d 个像素不一致: %v != %v", i, fast[i], generic[i])
+		}
+	}
+}
+
+func TestParseImageFormat(t *testing.T) {
+	if f, err := ParseImageFormat("JPG"); err != nil || f != FormatJPEG || f.MIMEType() != "image/jpeg" {
+		t.Fatalf("jpg 应解析为 JPEG，实际为 %q, %v", f, err)
+	}
+	if _, err := ParseImageFormat("bmp"); err == nil {
+		t.Fatalf("不支持的格式应返回错误")
+	}
+}
+
+func TestGetDataURI(t *testing.T) {
+	id, uri, err := GetDataURI(4, Numeric, Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	if !strings.HasPrefix(uri, "data:image/png;base64,") {
+		t.Fatalf("data URI 前缀错误: %.40s", uri)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatalf("base64 解码失败: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("PNG 解码失败: %v", err)
+	}
+	if getCaptcha(id) == nil {
+		t.Fatalf("验证码 %s 未存储", id)
+	}
+}
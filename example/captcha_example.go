@@ -8,9 +8,9 @@ package example
 
 // func main() {
 // 	// 生成一个长度为 6 的混合验证码
-// 	captchaID, code, err := captcha.GetAndSave(6, captcha.AplusN, "./test.jpg")
-// 	// captcha.GetOne(6,captcha.AplusN) Base64 Code
-// 	// captcha.GetImage(5,captcha.AplusN)
+// 	captchaID, code, err := captcha.GetAndSave(6, captcha.AplusN, captcha.Mid, "test.png") // 需先调用 captcha.SetSaveConfig
+// 	// captcha.GetOne(6, captcha.AplusN, captcha.Mid) Base64 Code
+// 	// captcha.GetImage(5, captcha.AplusN, captcha.Hard)
 // 	if err != nil {
 // 		fmt.Println("生成验证码失败:", err)
 // 		return
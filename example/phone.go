@@ -2,18 +2,22 @@
 package example
 
 // import (
+// 	"context"
 // 	"fmt"
 // 	"github.com/yowaimono/captcha"
 // )
 
 // func main() {
-// 	phoneNumber := "1234567890"
-// 	templateCode := "your_template_code"
-// 	templateContent := `{"code":"{{.code1}}"}`
+// 	phoneNumber := "13800138000"
+// 	template := captcha.SMSTemplate{
+// 		TemplateCode: "your_template_code",
+// 		CodeParam:    "code",
+// 		Params:       map[string]string{"product": "your_product"},
+// 	}
 // 	captchaLength := 6
 
 // 	// 发送验证码
-// 	captchaCode, err := captcha.SendCaptchaToPhone(phoneNumber, templateCode, templateContent, captchaLength)
+// 	captchaCode, err := captcha.SendCaptchaToPhoneTemplate(context.Background(), phoneNumber, template, captchaLength)
 // 	if err != nil {
 // 		fmt.Println("Failed to send captcha:", err)
 // 		return
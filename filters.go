@@ -0,0 +1,251 @@
+package captcha
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// Filter 是在验证码绘制完成后作用于整张图片的滤镜，多个滤镜按顺序组成滤镜链
+type Filter interface {
+	Apply(img *image.RGBA)
+}
+
+// WaveFilter 正弦波扭曲，沿横向周期性地上下偏移像素
+type WaveFilter struct {
+	Amplitude float64 // 振幅（像素）
+	Period    float64 // 周期（像素）
+}
+
+// SwirlFilter 漩涡扭曲，以图片中心附近的随机点为圆心旋转像素，越靠近圆心旋转角度越大
+type SwirlFilter struct {
+	Strength float64 // 圆心处的旋转角度（弧度）
+	Radius   float64 // 影响半径（像素），为0时取图片短边的一半
+}
+
+// PerspectiveFilter 随机透视倾斜，将图片四个角随机移动后做投影变换
+type PerspectiveFilter struct {
+	Strength float64 // 角点最大偏移量占图片宽高的比例，建议 0~0.2
+}
+
+// ElasticFilter 弹性扭曲，使用经高斯平滑的随机位移场使笔画产生局部弯曲
+type ElasticFilter struct {
+	Alpha float64 // 最大位移（像素）
+	Sigma float64 // 位移场的平滑程度（像素），越大扭曲越平缓
+}
+
+// SetFilters 设置指定难度级别预设中的滤镜链，不传滤镜则清空该级别的滤镜
+func SetFilters(level NoiseLevel, filters ...Filter) {
+	updateNoisePreset(level, func(preset *NoisePreset) {
+		preset.Filters = filters
+	})
+	log.Info("Set %d filter(s) for noise level: %v", len(filters), level)
+}
+
+// 以像素为单位的滤镜参数需要随缩放倍数放大，内置滤镜实现此接口
+type scalableFilter interface {
+	scaled(scale float64) Filter
+}
+
+// 依次应用滤镜链
+func applyFilters(img *image.RGBA, filters []Filter, scale float64) {
+	for _, f := range filters {
+		if sf, ok := f.(scalableFilter); ok && scale != 1 {
+			f = sf.scaled(scale)
+		}
+		f.Apply(img)
+	}
+}
+
+func (f WaveFilter) scaled(scale float64) Filter {
+	return WaveFilter{Amplitude: f.Amplitude * scale, Period: f.Period * scale}
+}
+
+func (f SwirlFilter) scaled(scale float64) Filter {
+	return SwirlFilter{Strength: f.Strength, Radius: f.Radius * scale}
+}
+
+func (f ElasticFilter) scaled(scale float64) Filter {
+	return ElasticFilter{Alpha: f.Alpha * scale, Sigma: f.Sigma * scale}
+}
+
+// Apply 实现 Filter 接口
+func (f WaveFilter) Apply(img *image.RGBA) {
+	if f.Amplitude == 0 || f.Period <= 0 {
+		return
+	}
+	phase := rand.Float64() * 2 * math.Pi
+	warp(img, func(x, y float64) (float64, float64) {
+		return x, y + f.Amplitude*math.Sin(2*math.Pi*x/f.Period+phase)
+	})
+}
+
+// Apply 实现 Filter 接口
+func (f SwirlFilter) Apply(img *image.RGBA) {
+	if f.Strength == 0 {
+		return
+	}
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	radius := f.Radius
+	if radius <= 0 {
+		radius = math.Min(w, h) / 2
+	}
+	cx := w/2 + (rand.Float64()-0.5)*w/4
+	cy := h/2 + (rand.Float64()-0.5)*h/4
+	strength := f.Strength
+	if rand.Intn(2) == 0 {
+		strength = -strength
+	}
+
+	warp(img, func(x, y float64) (float64, float64) {
+		dx, dy := x-cx, y-cy
+		r := math.Hypot(dx, dy)
+		if r >= radius {
+			return x, y
+		}
+		t := 1 - r/radius
+		sin, cos := math.Sincos(strength * t * t)
+		return cx + dx*cos - dy*sin, cy + dx*sin + dy*cos
+	})
+}
+
+// Apply 实现 Filter 接口
+func (f PerspectiveFilter) Apply(img *image.RGBA) {
+	if f.Strength <= 0 {
+		return
+	}
+	w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+	jitter := func(v, size float64) float64 {
+		return v + (rand.Float64()*2-1)*f.Strength*size
+	}
+
+	// 输出图片的单位正方形映射到源图片中随机偏移后的四边形
+	m := squareToQuad(
+		jitter(0, w), jitter(0, h),
+		jitter(w, w), jitter(0, h),
+		jitter(w, w), jitter(h, h),
+		jitter(0, w), jitter(h, h),
+	)
+	warp(img, func(x, y float64) (float64, float64) {
+		u, v := x/w, y/h
+		z := m[6]*u + m[7]*v + 1
+		return (m[0]*u + m[1]*v + m[2]) / z, (m[3]*u + m[4]*v + m[5]) / z
+	})
+}
+
+// Apply 实现 Filter 接口
+func (f ElasticFilter) Apply(img *image.RGBA) {
+	if f.Alpha == 0 {
+		return
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	fieldX := randomField(w, h, f.Sigma)
+	fieldY := randomField(w, h, f.Sigma)
+
+	warp(img, func(x, y float64) (float64, float64) {
+		i := min(int(y), h-1)*w + min(int(x), w-1)
+		return x + fieldX[i]*f.Alpha, y + fieldY[i]*f.Alpha
+	})
+}
+
+// 按逆映射函数扭曲图片，fn 将输出像素中心坐标映射为源图片坐标，源坐标越界时取边缘像素
+func warp(img *image.RGBA, fn func(x, y float64) (float64, float64)) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	src := newCanvas(w, h)
+	defer releaseCanvas(src)
+	copy(src.Pix, img.Pix)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := fn(float64(x)+0.5, float64(y)+0.5)
+			o := y*img.Stride + x*4
+			sampleRGBA(src, sx-0.5, sy-0.5, img.Pix[o:o+4:o+4])
+		}
+	}
+}
+
+// 双线性插值采样 RGBA 图片并写入 out，越界部分取边缘像素
+func sampleRGBA(src *image.RGBA, fx, fy float64, out []uint8) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	fx = math.Max(0, math.Min(fx, float64(w-1)))
+	fy = math.Max(0, math.Min(fy, float64(h-1)))
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	p00 := src.Pix[y0*src.Stride+x0*4:]
+	p10 := src.Pix[y0*src.Stride+x1*4:]
+	p01 := src.Pix[y1*src.Stride+x0*4:]
+	p11 := src.Pix[y1*src.Stride+x1*4:]
+	for c := 0; c < 4; c++ {
+		top := float64(p00[c])*(1-tx) + float64(p10[c])*tx
+		bottom := float64(p01[c])*(1-tx) + float64(p11[c])*tx
+		out[c] = uint8(top*(1-ty) + bottom*ty + 0.5)
+	}
+}
+
+// 计算将单位正方形 (0,0),(1,0),(1,1),(0,1) 映射到四边形 (x0,y0)...(x3,y3) 的投影变换矩阵
+func squareToQuad(x0, y0, x1, y1, x2, y2, x3, y3 float64) [8]float64 {
+	dx1, dy1 := x1-x2, y1-y2
+	dx2, dy2 := x3-x2, y3-y2
+	sx, sy := x0-x1+x2-x3, y0-y1+y2-y3
+
+	if sx == 0 && sy == 0 {
+		// 仿射变换
+		return [8]float64{x1 - x0, x3 - x0, x0, y1 - y0, y3 - y0, y0, 0, 0}
+	}
+
+	det := dx1*dy2 - dx2*dy1
+	g := (sx*dy2 - dx2*sy) / det
+	hh := (dx1*sy - sx*dy1) / det
+	return [8]float64{
+		x1 - x0 + g*x1, x3 - x0 + hh*x3, x0,
+		y1 - y0 + g*y1, y3 - y0 + hh*y3, y0,
+		g, hh,
+	}
+}
+
+// 生成经高斯平滑并归一化到 [-1, 1] 的随机位移场
+func randomField(w, h int, sigma float64) []float64 {
+	field := make([]float64, w*h)
+	for i := range field {
+		field[i] = rand.Float64()*2 - 1
+	}
+
+	// 三次盒式模糊近似高斯模糊
+	radius := max(1, int(sigma))
+	tmp := make([]float64, w*h)
+	for i := 0; i < 3; i++ {
+		boxBlur(field, tmp, w, h, radius, 1, w)
+		boxBlur(tmp, field, h, w, radius, w, 1)
+	}
+
+	var peak float64
+	for _, v := range field {
+		peak = math.Max(peak, math.Abs(v))
+	}
+	if peak > 0 {
+		for i := range field {
+			field[i] /= peak
+		}
+	}
+	return field
+}
+
+// 一维盒式模糊，沿 step 方向模糊长度为 n 的 lines 条线，line 为相邻线之间的间隔
+func boxBlur(src, dst []float64, n, lines, radius, step, line int) {
+	for l := 0; l < lines; l++ {
+		base := l * line
+		for i := 0; i < n; i++ {
+			var sum float64
+			count := 0
+			for k := max(0, i-radius); k <= min(n-1, i+radius); k++ {
+				sum += src[base+k*step]
+				count++
+			}
+			dst[base+i*step] = sum / float64(count)
+		}
+	}
+}
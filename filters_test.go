@@ -0,0 +1,47 @@
+package captcha
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestFiltersKeepSolidImage(t *testing.T) {
+	filters := []Filter{
+		WaveFilter{Amplitude: 3, Period: 20},
+		SwirlFilter{Strength: 1},
+		PerspectiveFilter{Strength: 0.1},
+		ElasticFilter{Alpha: 2, Sigma: 3},
+	}
+	for _, f := range filters {
+		img := newCanvas(60, 30)
+		fillRGBA(img, color.RGBA{10, 20, 30, 255})
+		f.Apply(img)
+
+		// 纯色图片经任何几何扭曲后都应保持不变
+		for y := 0; y < 30; y++ {
+			for x := 0; x < 60; x++ {
+				if got := img.RGBAAt(x, y); got != (color.RGBA{10, 20, 30, 255}) {
+					t.Fatalf("%T: (%d, %d) 颜色变为 %v", f, x, y, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSquareToQuadIdentity(t *testing.T) {
+	m := squareToQuad(0, 0, 100, 0, 100, 50, 0, 50)
+	u, v := 0.25, 0.5
+	z := m[6]*u + m[7]*v + 1
+	x, y := (m[0]*u+m[1]*v+m[2])/z, (m[3]*u+m[4]*v+m[5])/z
+	if x != 25 || y != 25 {
+		t.Fatalf("矩形映射应为线性缩放，实际为 (%v, %v)", x, y)
+	}
+
+	// 一般四边形的角点应精确映射
+	m = squareToQuad(3, 2, 90, 7, 95, 48, 1, 41)
+	z = m[6] + m[7] + 1
+	x, y = (m[0]+m[1]+m[2])/z, (m[3]+m[4]+m[5])/z
+	if diff := (x-95)*(x-95) + (y-48)*(y-48); diff > 1e-9 {
+		t.Fatalf("(1,1) 应映射到 (95,48)，实际为 (%v, %v)", x, y)
+	}
+}
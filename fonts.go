@@ -0,0 +1,154 @@
+package captcha
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+var (
+	registeredFonts []*opentype.Font
+	fontLock        sync.RWMutex
+
+	defaultFont     *opentype.Font
+	defaultFontErr  error
+	defaultFontOnce sync.Once
+
+	// 字体面缓存，faceKey -> *sync.Pool。字体面不能并发使用，因此每个 goroutine 从池中独占借用
+	faceCache sync.Map
+)
+
+// 字体面缓存的键
+type faceKey struct {
+	font *opentype.Font
+	size float64
+}
+
+// RegisterFont 注册 TrueType/OpenType 字体，支持 TTC/OTC 字体集合（集合中的每个字体都会被注册）
+//
+// 注册了字体后，每个字符都会从已注册的字体中随机选择一个进行绘制，不再使用内置的 goregular。
+func RegisterFont(data []byte) error {
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		log.Error("Failed to parse font: %v", err)
+		return err
+	}
+
+	fonts := make([]*opentype.Font, 0, collection.NumFonts())
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			log.Error("Failed to load font %d from collection: %v", i, err)
+			return err
+		}
+		fonts = append(fonts, f)
+	}
+
+	fontLock.Lock()
+	defer fontLock.Unlock()
+	registeredFonts = append(registeredFonts, fonts...)
+	log.Info("Registered %d font(s), total: %d", len(fonts), len(registeredFonts))
+	return nil
+}
+
+// RegisterFontFile 从文件注册字体
+func RegisterFontFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error("Failed to read font file: %v", err)
+		return err
+	}
+	return RegisterFont(data)
+}
+
+// RegisterFontFS 注册 fsys 中所有匹配 pattern（fs.Glob 语法）的字体文件
+func RegisterFontFS(fsys fs.FS, pattern string) error {
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no font files match pattern %q", pattern)
+	}
+
+	for _, name := range matches {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			log.Error("Failed to read font file %s: %v", name, err)
+			return err
+		}
+		if err := RegisterFont(data); err != nil {
+			return fmt.Errorf("register font %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ResetFonts 清空已注册的字体，恢复使用内置的 goregular
+func ResetFonts() {
+	fontLock.Lock()
+	defer fontLock.Unlock()
+	registeredFonts = nil
+	faceCache.Clear()
+	log.Info("Reset registered fonts")
+}
+
+// 从已注册的字体中为字符随机选择一个包含该字形的字体，没有合适的字体时返回 nil
+func pickFont(char rune) *opentype.Font {
+	fontLock.RLock()
+	defer fontLock.RUnlock()
+
+	var buf sfnt.Buffer
+	candidates := make([]*opentype.Font, 0, len(registeredFonts))
+	for _, f := range registeredFonts {
+		if idx, err := f.GlyphIndex(&buf, char); err == nil && idx != 0 {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// 返回内置的 goregular 字体，只解析一次
+func loadDefaultFont() (*opentype.Font, error) {
+	defaultFontOnce.Do(func() {
+		defaultFont, defaultFontErr = opentype.Parse(goregular.TTF)
+		if defaultFontErr != nil {
+			log.Error("Failed to parse default font: %v", defaultFontErr)
+		}
+	})
+	return defaultFont, defaultFontErr
+}
+
+// 从缓存借用指定字体和字号的字体面，用完后需调用 releaseFace 归还
+func acquireFace(f *opentype.Font, size float64) (font.Face, error) {
+	key := faceKey{font: f, size: size}
+	if pool, ok := faceCache.Load(key); ok {
+		if face, ok := pool.(*sync.Pool).Get().(font.Face); ok {
+			return face, nil
+		}
+	}
+
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+// 归还借用的字体面
+func releaseFace(f *opentype.Font, size float64, face font.Face) {
+	pool, _ := faceCache.LoadOrStore(faceKey{font: f, size: size}, &sync.Pool{})
+	pool.(*sync.Pool).Put(face)
+}
@@ -0,0 +1,33 @@
+package captcha
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+)
+
+func TestRegisterFontFS(t *testing.T) {
+	defer ResetFonts()
+
+	fsys := fstest.MapFS{
+		"fonts/bold.ttf":   &fstest.MapFile{Data: gobold.TTF},
+		"fonts/italic.ttf": &fstest.MapFile{Data: goitalic.TTF},
+		"fonts/readme.txt": &fstest.MapFile{Data: []byte("not a font")},
+	}
+	if err := RegisterFontFS(fsys, "fonts/*.ttf"); err != nil {
+		t.Fatalf("注册字体失败: %v", err)
+	}
+	if pickFont('A') == nil {
+		t.Fatalf("应从已注册的字体中选择")
+	}
+	if err := RegisterFontFS(fsys, "fonts/*.txt"); err == nil {
+		t.Fatalf("非字体文件应注册失败")
+	}
+
+	ResetFonts()
+	if pickFont('A') != nil {
+		t.Fatalf("重置后不应有已注册的字体")
+	}
+}
@@ -16,6 +16,9 @@ const (
 	AplusN CaptchaFormat = "A+N"   // 大写字母和数字混合
 	aPlusN CaptchaFormat = "a+N"   // 小写字母和数字混合
 	aPlusA CaptchaFormat = "a+A"   // 大写和小写字母混合
+
+	Numeric      CaptchaFormat = "numeric"      // 纯数字
+	AlphaNumeric CaptchaFormat = "alphanumeric" // 同 Mixed，大小写字母和数字混合
 )
 
 // 生成指定长度和格式的随机验证码
@@ -26,7 +29,7 @@ func generateCaptchaCode(length int, format CaptchaFormat) string {
 	var charset string
 
 	switch format {
-	case Mixed:
+	case Mixed, AlphaNumeric:
 		charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 		log.Info("Using charset for Mixed format")
 	case AplusN:
@@ -38,6 +41,9 @@ func generateCaptchaCode(length int, format CaptchaFormat) string {
 	case aPlusA:
 		charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 		log.Info("Using charset for aPlusA format")
+	case Numeric:
+		charset = "0123456789"
+		log.Info("Using charset for Numeric format")
 	default:
 		charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 		log.Info("Using default charset")
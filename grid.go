@@ -0,0 +1,346 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// GridLayout 定义选图素材目录的组织方式
+type GridLayout string
+
+const (
+	GridLayoutDir    GridLayout = "dir"    // <root>/<label>/<image>，一级子目录名即标签
+	GridLayoutPrefix GridLayout = "prefix" // <root>/<label>_<name>.<ext>，文件名中第一个下划线前的部分即标签
+)
+
+// GridConfig 存储选图验证码配置
+type GridConfig struct {
+	Dir      string     // 素材根目录，FS 为空时使用
+	FS       fs.FS      // 素材文件系统，优先于 Dir
+	Layout   GridLayout // 素材目录组织方式，默认 GridLayoutDir
+	Labels   []string   // 可作为题目的标签池，为空时使用素材中的全部标签
+	Rows     int        // 网格行数，默认3
+	Cols     int        // 网格列数，默认3
+	TileSize int        // 每个格子的边长（像素），默认100
+	Gap      int        // 格子之间的间距（像素），默认4，负数表示无间距
+}
+
+var (
+	gridConfig GridConfig
+	gridTiles  map[string][]string // 标签 -> 素材路径
+	gridLock   sync.RWMutex
+)
+
+// ErrGridNotConfigured 表示尚未调用 SetGridConfig
+var ErrGridNotConfigured = errors.New("grid captcha is not configured")
+
+// SetGridConfig 设置选图验证码配置，并扫描素材目录建立标签索引
+func SetGridConfig(config GridConfig) error {
+	if config.FS == nil {
+		if config.Dir == "" {
+			return errors.New("grid captcha requires Dir or FS")
+		}
+		config.FS = os.DirFS(config.Dir)
+	}
+	if config.Layout == "" {
+		config.Layout = GridLayoutDir
+	}
+	if config.Rows <= 0 {
+		config.Rows = 3
+	}
+	if config.Cols <= 0 {
+		config.Cols = 3
+	}
+	if config.TileSize <= 0 {
+		config.TileSize = 100
+	}
+	if config.Gap < 0 {
+		config.Gap = 0
+	} else if config.Gap == 0 {
+		config.Gap = 4
+	}
+
+	tiles, err := scanGridTiles(config.FS, config.Layout)
+	if err != nil {
+		log.Error("Failed to scan grid tiles: %v", err)
+		return err
+	}
+	if len(tiles) < 2 {
+		return fmt.Errorf("grid captcha needs images of at least 2 labels, found %d", len(tiles))
+	}
+
+	if len(config.Labels) == 0 {
+		for label := range tiles {
+			config.Labels = append(config.Labels, label)
+		}
+		sort.Strings(config.Labels)
+	}
+	for _, label := range config.Labels {
+		if len(tiles[label]) == 0 {
+			return fmt.Errorf("grid label %q has no images", label)
+		}
+	}
+
+	gridLock.Lock()
+	defer gridLock.Unlock()
+	gridConfig = config
+	gridTiles = tiles
+	log.Info("Grid captcha configured with %d labels, label pool: %v", len(tiles), config.Labels)
+	return nil
+}
+
+// 按目录组织方式扫描素材，返回标签到素材路径的映射
+func scanGridTiles(fsys fs.FS, layout GridLayout) (map[string][]string, error) {
+	tiles := make(map[string][]string)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isGridImage(p) {
+			return nil
+		}
+
+		var label string
+		switch layout {
+		case GridLayoutDir:
+			dir, _, found := strings.Cut(p, "/")
+			if !found {
+				return nil
+			}
+			label = dir
+		case GridLayoutPrefix:
+			prefix, _, found := strings.Cut(path.Base(p), "_")
+			if !found {
+				return nil
+			}
+			label = prefix
+		default:
+			return fmt.Errorf("unknown grid layout: %q", layout)
+		}
+
+		tiles[label] = append(tiles[label], p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tiles, nil
+}
+
+func isGridImage(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// GetGridImage 生成一张选图验证码，返回验证码ID、题目标签和image.Image对象
+func GetGridImage() (string, string, image.Image, error) {
+	gridLock.RLock()
+	config, tiles := gridConfig, gridTiles
+	gridLock.RUnlock()
+	if tiles == nil {
+		log.Error("GetGridImage called before SetGridConfig")
+		return "", "", nil, ErrGridNotConfigured
+	}
+
+	// 随机选择题目标签
+	label := config.Labels[rand.Intn(len(config.Labels))]
+	log.Info("Selected grid label: %s", label)
+
+	// 随机决定正确格子数量，至少1个，至多一半
+	total := config.Rows * config.Cols
+	correctCount := 1 + rand.Intn(min(len(tiles[label]), max(1, total/2)))
+
+	var others []string
+	for l, paths := range tiles {
+		if l != label {
+			others = append(others, paths...)
+		}
+	}
+
+	// 打乱格子位置，前 correctCount 个位置放置正确素材
+	positions := rand.Perm(total)
+	correctPaths := pickGridPaths(tiles[label], correctCount)
+	otherPaths := pickGridPaths(others, total-correctCount)
+
+	width := config.Cols*config.TileSize + (config.Cols+1)*config.Gap
+	height := config.Rows*config.TileSize + (config.Rows+1)*config.Gap
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{255, 255, 255, 255}), image.Point{}, xdraw.Src)
+
+	var selection []int
+	for i, pos := range positions {
+		var p string
+		if i < correctCount {
+			p = correctPaths[i]
+			selection = append(selection, pos)
+		} else {
+			p = otherPaths[i-correctCount]
+		}
+
+		tile, err := loadImage(config.FS, p)
+		if err != nil {
+			log.Error("Failed to load grid tile %s: %v", p, err)
+			return "", "", nil, err
+		}
+
+		row, col := pos/config.Cols, pos%config.Cols
+		x := config.Gap + col*(config.TileSize+config.Gap)
+		y := config.Gap + row*(config.TileSize+config.Gap)
+		cell := image.Rect(x, y, x+config.TileSize, y+config.TileSize)
+		xdraw.Draw(img, cell, perturbTile(tile, config.TileSize), image.Point{}, xdraw.Src)
+	}
+	log.Info("Assembled %dx%d grid image", config.Rows, config.Cols)
+
+	captchaID := generateCaptchaID()
+	storeCaptchaInfo(captchaID, &CaptchaInfo{
+		Type: TypeGrid,
+		Code: formatGridSelection(selection),
+	})
+	expireCaptchaAfter(captchaID, 60*time.Second)
+
+	return captchaID, label, img, nil
+}
+
+// GetGridBase64 生成一张选图验证码，返回验证码ID、题目标签和base64编码的图片
+func GetGridBase64() (string, string, string, error) {
+	captchaID, label, img, err := GetGridImage()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var imgBuf bytes.Buffer
+	if err := pngEncoder.Encode(&imgBuf, img); err != nil {
+		log.Error("Failed to encode grid image to PNG: %v", err)
+		return "", "", "", err
+	}
+
+	return captchaID, label, base64.StdEncoding.EncodeToString(imgBuf.Bytes()), nil
+}
+
+// VerifyGrid 验证用户选中的格子（按行优先从0开始编号）是否正确
+//
+// 每个选图验证码只能验证一次，无论是否正确，验证后都需要重新生成。
+func VerifyGrid(captchaID string, selected []int) bool {
+	return Verify(captchaID, formatGridSelection(selected))
+}
+
+// 从素材中随机选取 n 个，素材不足时允许重复
+func pickGridPaths(paths []string, n int) []string {
+	picked := make([]string, n)
+	if len(paths) >= n {
+		for i, j := range rand.Perm(len(paths))[:n] {
+			picked[i] = paths[j]
+		}
+		return picked
+	}
+	for i := range picked {
+		picked[i] = paths[rand.Intn(len(paths))]
+	}
+	return picked
+}
+
+// 从 fsys 读取并解码图片
+func loadImage(fsys fs.FS, p string) (image.Image, error) {
+	file, err := fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// 对素材做轻微扰动（随机裁剪、镜像、亮度/对比度抖动和像素噪声），使同一素材每次的哈希都不同
+func perturbTile(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	cropFrac := 0.88 + rand.Float64()*0.12
+	cw, ch := max(1, int(float64(b.Dx())*cropFrac)), max(1, int(float64(b.Dy())*cropFrac))
+	x0 := b.Min.X + rand.Intn(b.Dx()-cw+1)
+	y0 := b.Min.Y + rand.Intn(b.Dy()-ch+1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, image.Rect(x0, y0, x0+cw, y0+ch), xdraw.Src, nil)
+
+	if rand.Intn(2) == 0 {
+		for y := 0; y < size; y++ {
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+size*4]
+			for l, r := 0, size-1; l < r; l, r = l+1, r-1 {
+				for c := 0; c < 4; c++ {
+					row[l*4+c], row[r*4+c] = row[r*4+c], row[l*4+c]
+				}
+			}
+		}
+	}
+
+	brightness := rand.Float64()*40 - 20
+	contrast := 0.9 + rand.Float64()*0.2
+	for i := 0; i < len(dst.Pix); i += 4 {
+		noise := float64(rand.Intn(13) - 6)
+		for c := 0; c < 3; c++ {
+			v := (float64(dst.Pix[i+c])-128)*contrast + 128 + brightness + noise
+			dst.Pix[i+c] = uint8(max(0, min(255, v)))
+		}
+	}
+
+	return dst
+}
+
+// 将格子编号去重排序后拼接为 "1,4,7" 形式
+func formatGridSelection(selected []int) string {
+	seen := make(map[int]bool, len(selected))
+	var sorted []int
+	for _, i := range selected {
+		if !seen[i] {
+			seen[i] = true
+			sorted = append(sorted, i)
+		}
+	}
+	sort.Ints(sorted)
+
+	parts := make([]string, len(sorted))
+	for i, n := range sorted {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// 比较用户提交的 "7, 1,4" 形式选择与正确答案
+func matchGridSelection(expected, userInput string) bool {
+	var selected []int
+	for _, part := range strings.Split(userInput, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return false
+		}
+		selected = append(selected, n)
+	}
+	return formatGridSelection(selected) == expected
+}
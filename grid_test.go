@@ -0,0 +1,88 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+func gridTestFS(t *testing.T) fstest.MapFS {
+	t.Helper()
+
+	fsys := fstest.MapFS{}
+	colors := map[string]color.RGBA{
+		"cat":  {200, 80, 80, 255},
+		"bus":  {80, 200, 80, 255},
+		"tree": {80, 80, 200, 255},
+	}
+	for label, col := range colors {
+		for _, name := range []string{"a.png", "b.png", "c.png"} {
+			img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+			for i := 0; i < len(img.Pix); i += 4 {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = col.R, col.G, col.B, col.A
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatalf("编码素材失败: %v", err)
+			}
+			fsys[label+"/"+name] = &fstest.MapFile{Data: buf.Bytes()}
+		}
+	}
+	return fsys
+}
+
+func TestGridCaptcha(t *testing.T) {
+	if err := SetGridConfig(GridConfig{FS: gridTestFS(t), Labels: []string{"cat"}, TileSize: 40}); err != nil {
+		t.Fatalf("配置选图验证码失败: %v", err)
+	}
+
+	captchaID, label, img, err := GetGridImage()
+	if err != nil {
+		t.Fatalf("生成选图验证码失败: %v", err)
+	}
+	if label != "cat" {
+		t.Fatalf("题目标签应为 cat，实际为 %s", label)
+	}
+	if got := img.Bounds().Dx(); got != 3*40+4*4 {
+		t.Fatalf("图片宽度不正确: %d", got)
+	}
+
+	info := getCaptcha(captchaID)
+	if info == nil || info.Type != TypeGrid {
+		t.Fatalf("未存储选图验证码")
+	}
+	if Verify(captchaID, info.Code+",99") {
+		t.Fatalf("多选的格子不应通过验证")
+	}
+	// 选错一次后验证码即失效，正确答案也不能再通过，无法穷举
+	if Verify(captchaID, info.Code) {
+		t.Fatalf("选错后验证码应失效")
+	}
+
+	captchaID, _, _, err = GetGridImage()
+	if err != nil {
+		t.Fatalf("生成选图验证码失败: %v", err)
+	}
+	info = getCaptcha(captchaID)
+	if !Verify(captchaID, " "+info.Code+" ") {
+		t.Fatalf("正确选择未通过验证")
+	}
+	if Verify(captchaID, info.Code) {
+		t.Fatalf("验证通过后选择不应能再次使用")
+	}
+}
+
+func TestMatchGridSelection(t *testing.T) {
+	if !matchGridSelection("1,4,7", "7, 1,4,4") {
+		t.Fatalf("乱序和重复的选择应视为相同")
+	}
+	if matchGridSelection("1,4,7", "1,4") {
+		t.Fatalf("漏选的格子不应通过验证")
+	}
+	if matchGridSelection("1", "a") {
+		t.Fatalf("非法输入不应通过验证")
+	}
+}
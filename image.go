@@ -4,212 +4,452 @@ package captcha
 import (
 	"image"
 	"image/color"
+	"image/jpeg"
 	"math"
 	"math/rand"
-	"time"
+	"sync"
 
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
+
+	log "github.com/yowaimono/captcha/internal/log"
 )
 
-// 创建验证码图片
-func createCaptchaImage(code string, noiseLevel NoiseLevel) image.Image {
-	// 根据验证码的长度动态调整图片宽度
-	charWidth := 20 // 假设每个字符需要20像素的宽度
-	width := len(code) * charWidth + 20 // 加上左右边距
-	height := 40
-	img := image.NewRGBA(image.Rect(0, 0, width, height))
-
-	// 填充背景色
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			img.Set(x, y, color.RGBA{255, 255, 255, 255})
+const (
+	fontSize    = 24 // 字号（磅）
+	charSpacing = 2  // 字符之间的额外间距（像素）
+	marginX     = 10 // 左右边距（像素）
+	minHeight   = 40 // 最小图片高度（像素）
+)
+
+// ImageConfig 存储验证码图片的外观配置
+//
+// Width、Height 以 1x 的逻辑像素为单位，实际输出尺寸为其乘以 Scale。
+type ImageConfig struct {
+	Theme       string      // 颜色主题名称，如 ThemeLight、ThemeDark 或通过 RegisterTheme 注册的主题
+	Background  Background  // 背景，优先于主题的背景，默认白色纯色背景
+	Transparent bool        // 使用透明背景，图片可以融入任意页面；对比度按主题背景色检查
+	MinContrast float64     // 字符颜色与背景的最小对比度（WCAG 对比度，1~21），为0时默认3，设为1可关闭检查
+	Scale       float64     // 高分屏缩放倍数（如 1、2、3），字号、间距、噪点和线宽等比例放大，为0时为1
+	Width       int         // 固定宽度，为0时按字符宽度自动计算；字符放不下时自动缩小字号
+	Height      int         // 固定高度，为0时按字体高度自动计算；字号随高度等比例变化
+	Format      ImageFormat // 输出格式，默认 PNG；JPEG 和 GIF 不支持透明，透明背景会合成到主题背景色上
+	Quality     int         // JPEG 质量（1~100），为0时默认75
+}
+
+// 由图片配置和主题解析出的绘制样式
+type renderStyle struct {
+	background  Background   // 为 nil 时表示透明背景
+	pageColor   color.RGBA   // 页面背景色，透明背景时用于对比度检查
+	palette     []color.RGBA // 字符调色板，为空时随机生成颜色
+	noiseColor  color.RGBA
+	minContrast float64
+	scale       float64
+	width       int
+	height      int
+	format      ImageFormat
+	quality     int
+}
+
+var (
+	imageConfig     ImageConfig
+	imageConfigLock sync.RWMutex
+)
+
+// SetImageConfig 设置验证码图片的外观配置
+func SetImageConfig(config ImageConfig) {
+	imageConfigLock.Lock()
+	defer imageConfigLock.Unlock()
+	imageConfig = config
+	log.Info("Set image config: %+v", config)
+}
+
+// 根据当前图片配置和主题解析绘制样式
+func currentRenderStyle() (renderStyle, error) {
+	imageConfigLock.RLock()
+	config := imageConfig
+	imageConfigLock.RUnlock()
+
+	style := renderStyle{
+		background:  config.Background,
+		pageColor:   color.RGBA{255, 255, 255, 255},
+		noiseColor:  color.RGBA{0, 0, 0, 255},
+		minContrast: config.MinContrast,
+		scale:       config.Scale,
+		width:       config.Width,
+		height:      config.Height,
+		quality:     config.Quality,
+	}
+	format, err := ParseImageFormat(string(config.Format))
+	if err != nil {
+		return renderStyle{}, err
+	}
+	style.format = format
+	if config.Theme != "" {
+		theme, err := lookupTheme(config.Theme)
+		if err != nil {
+			return renderStyle{}, err
 		}
+		style.pageColor = theme.BackgroundColor
+		style.palette = theme.Palette
+		style.noiseColor = theme.NoiseColor
+		if style.background == nil {
+			style.background = theme.Background
+		}
+		// 主题要求的对比度不能被图片配置降低
+		if theme.MinContrast > 0 && (style.minContrast == 0 || theme.MinContrast > style.minContrast) {
+			style.minContrast = theme.MinContrast
+		}
+	}
+	if style.background == nil {
+		style.background = SolidBackground{Color: style.pageColor}
 	}
+	if config.Transparent {
+		style.background = nil
+	}
+	if style.minContrast == 0 {
+		style.minContrast = 3
+	}
+	if style.scale <= 0 {
+		style.scale = 1
+	}
+	if style.quality <= 0 {
+		style.quality = jpeg.DefaultQuality
+	}
+	return style, nil
+}
+
+// 单个字符的布局信息
+type glyphSlot struct {
+	char    rune
+	font    *opentype.Font
+	face    font.Face
+	x       int // 字符起点（基线左端）的横坐标
+	advance int // 字符宽度
+}
 
-	// 加载字体
-	ttfFont, err := opentype.Parse(goregular.TTF)
+// 创建验证码图片
+//
+// 返回的图片像素缓冲区来自缓冲池，编码完成且不再使用时可调用 releaseCanvas 归还
+func createCaptchaImage(code string, noiseLevel NoiseLevel) (*image.RGBA, error) {
+	// 查找难度预设
+	preset, err := lookupNoisePreset(noiseLevel)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	// 创建字体面
-	face, err := opentype.NewFace(ttfFont, &opentype.FaceOptions{
-		Size:    24,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
+	// 解析主题、背景和尺寸
+	style, err := currentRenderStyle()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	// 绘制验证码
-	drawCaptcha(img, code, face)
+	return renderCaptcha(code, preset, style)
+}
 
-	if noiseLevel == Simple {
-		return img
+// 按难度预设和绘制样式绘制验证码图片
+func renderCaptcha(code string, preset NoisePreset, style renderStyle) (*image.RGBA, error) {
+	layout, err := layoutCaptcha(code, style)
+	if err != nil {
+		return nil, err
+	}
+	defer layout.release()
+
+	// 绘制背景，透明背景时清空画布
+	img := newCanvas(layout.width, layout.height)
+	if style.background != nil {
+		style.background.Draw(img)
+	} else {
+		clear(img.Pix)
 	}
-	// 添加噪点
-	addNoise(img, noiseLevel)
-
-	// 添加干扰线
-	addLines(img)
 
-	return img
-}
+	// 绘制验证码
+	palette := drawCaptcha(img, layout.slots, layout.baseline, preset, style)
 
-// 绘制验证码
-func drawCaptcha(img *image.RGBA, code string, face font.Face) {
-	_, height := img.Bounds().Dx(), img.Bounds().Dy()
-	rand.Seed(time.Now().UnixNano())
+	// 添加噪点
+	addNoise(img, preset.NoiseDensity, style.noiseColor, style.scale)
 
-	for i, char := range code {
-		// 随机颜色
-		col := color.RGBA{
-			uint8(rand.Intn(256)),
-			uint8(rand.Intn(256)),
-			uint8(rand.Intn(256)),
-			255,
-		}
+	// 添加干扰曲线
+	for _, stroke := range curveStrokes(img.Rect, scaledCurves(preset.Curves, style.scale), layout.slots, layout.baseline, palette) {
+		strokePolyline(img, stroke.pts, stroke.w0, stroke.w1, stroke.col)
+	}
 
-		// 随机倾斜角度
-		angle := rand.Float64()*20 - 10 // 倾斜角度在 -10 到 10 度之间
-		rad := angle * math.Pi / 180
+	// 应用扭曲滤镜链，噪点和干扰线随字符一起扭曲
+	applyFilters(img, preset.Filters, style.scale)
 
-		// 计算字符位置
-		x := 10 + i*20
-		y := height/2 + 10
+	return img, nil
+}
 
-		// 旋转字符
-		drawRotatedChar(img, char, x, y, rad, col, face)
-	}
+// 验证码的排版结果，光栅和 SVG 渲染共用
+type captchaLayout struct {
+	faces    *faceSet
+	slots    []glyphSlot
+	width    int
+	height   int
+	baseline int
 }
 
-// 绘制旋转字符
-func drawRotatedChar(img *image.RGBA, char rune, x, y int, rad float64, col color.RGBA, face font.Face) {
-	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+// 归还排版时借用的字体面
+func (l *captchaLayout) release() {
+	l.faces.release()
+}
 
-	// 创建一个新的图像来绘制旋转后的字符
-	rotatedImg := image.NewRGBA(image.Rect(0, 0, width, height))
+// 计算画布尺寸并排版字符，使用完后需调用 release
+func layoutCaptcha(code string, style renderStyle) (*captchaLayout, error) {
+	// 加载内置字体，没有注册字体或注册的字体缺少字形时使用
+	ttfFont, err := loadDefaultFont()
+	if err != nil {
+		return nil, err
+	}
 
-	// 绘制字符到新的图像
-	d := &font.Drawer{
-		Dst:  rotatedImg,
-		Src:  image.NewUniform(col),
-		Face: face,
-		Dot:  fixed.P(x, y),
+	// 字号、间距和边距按缩放倍数放大；指定了高度时字号随高度等比例变化
+	scale := style.scale
+	margin := int(math.Round(marginX * scale))
+	spacing := int(math.Round(charSpacing * scale))
+	size := fontSize * scale
+	if style.height > 0 {
+		size = fontSize * float64(style.height) / minHeight * scale
 	}
-	d.DrawString(string(char))
 
-	// 旋转图像
-	rotatedImg = rotateImage(rotatedImg, rad)
+	// 根据字形度量排版
+	faces := newFaceSet(ttfFont, size)
+	slots, textWidth, ascent, descent, err := layoutGlyphs(code, faces, spacing)
+	if err != nil {
+		faces.release()
+		return nil, err
+	}
 
-	// 将旋转后的图像绘制到原始图像
-	for i := 0; i < width; i++ {
-		for j := 0; j < height; j++ {
-			c := rotatedImg.At(i, j)
-			if _, _, _, a := c.RGBA(); a > 0 {
-				img.Set(i, j, c)
+	// 指定了宽度时，字符过长则缩小字号直到能放进画布
+	width := textWidth + 2*margin
+	if style.width > 0 {
+		canvasWidth := int(math.Round(float64(style.width) * scale))
+		for i := 0; i < 5 && width > canvasWidth && textWidth > 0; i++ {
+			fit := float64(canvasWidth-2*margin) / float64(textWidth) * 0.98
+			faces.release()
+			faces = newFaceSet(ttfFont, faces.size*max(0.1, fit))
+			if slots, textWidth, ascent, descent, err = layoutGlyphs(code, faces, spacing); err != nil {
+				faces.release()
+				return nil, err
 			}
+			width = textWidth + 2*margin
 		}
+		width = canvasWidth
 	}
+
+	height := max(int(math.Round(minHeight*scale)), ascent+descent+int(math.Round(8*scale)))
+	if style.height > 0 {
+		height = int(math.Round(float64(style.height) * scale))
+	}
+
+	// 文字在画布中水平、垂直居中
+	offsetX := (width - textWidth) / 2
+	for i := range slots {
+		slots[i].x += offsetX
+	}
+
+	return &captchaLayout{
+		faces:    faces,
+		slots:    slots,
+		width:    width,
+		height:   height,
+		baseline: (height + ascent - descent) / 2,
+	}, nil
 }
 
-// 旋转图像
-func rotateImage(img *image.RGBA, rad float64) *image.RGBA {
-	width, height := img.Bounds().Dx(), img.Bounds().Dy()
-	centerX, centerY := width/2, height/2
+// 一次绘制中使用的字体面集合，每个字符随机选择字体，同一字体共享字体面
+type faceSet struct {
+	size     float64
+	fallback *opentype.Font
+	faces    map[*opentype.Font]font.Face
+}
 
-	rotatedImg := image.NewRGBA(image.Rect(0, 0, width, height))
+// 字号四舍五入到 0.5 磅，避免自动适配宽度时产生过多不同字号的缓存
+func newFaceSet(fallback *opentype.Font, size float64) *faceSet {
+	return &faceSet{
+		size:     max(1, math.Round(size*2)/2),
+		fallback: fallback,
+		faces:    make(map[*opentype.Font]font.Face),
+	}
+}
+
+// 为字符随机选择字体并从缓存借用字体面
+func (s *faceSet) faceFor(char rune) (*opentype.Font, font.Face, error) {
+	f := pickFont(char)
+	if f == nil {
+		f = s.fallback
+	}
+	if face, ok := s.faces[f]; ok {
+		return f, face, nil
+	}
+	face, err := acquireFace(f, s.size)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.faces[f] = face
+	return f, face, nil
+}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// 计算旋转后的坐标
-			xx := int(float64(x-centerX)*math.Cos(rad)-float64(y-centerY)*math.Sin(rad)) + centerX
-			yy := int(float64(x-centerX)*math.Sin(rad)+float64(y-centerY)*math.Cos(rad)) + centerY
+// 归还借用的字体面
+func (s *faceSet) release() {
+	for f, face := range s.faces {
+		releaseFace(f, s.size, face)
+	}
+	clear(s.faces)
+}
 
-			if xx >= 0 && xx < width && yy >= 0 && yy < height {
-				rotatedImg.Set(xx, yy, img.At(x, y))
-			}
+// 根据每个字符的字形宽度排版，字符横坐标从0开始，返回字符位置、文字总宽度以及最大上升和下降高度
+func layoutGlyphs(code string, faces *faceSet, spacing int) ([]glyphSlot, int, int, int, error) {
+	var slots []glyphSlot
+	var ascent, descent int
+	x := 0
+	for _, char := range code {
+		f, face, err := faces.faceFor(char)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		advance, ok := face.GlyphAdvance(char)
+		if !ok {
+			advance = fixed.I(int(faces.size) / 2)
 		}
+		slots = append(slots, glyphSlot{char: char, font: f, face: face, x: x, advance: advance.Ceil()})
+		x += advance.Ceil() + spacing
+
+		metrics := face.Metrics()
+		ascent = max(ascent, metrics.Ascent.Ceil())
+		descent = max(descent, metrics.Descent.Ceil())
 	}
 
-	return rotatedImg
+	return slots, max(0, x-spacing), ascent, descent, nil
 }
 
-// 添加噪点
-func addNoise(img *image.RGBA, noiseLevel NoiseLevel) {
-	width, height := img.Bounds().Dx(), img.Bounds().Dy()
-	rand.Seed(time.Now().UnixNano())
+// 绘制验证码，返回字符使用的颜色
+func drawCaptcha(img *image.RGBA, slots []glyphSlot, baseline int, preset NoisePreset, style renderStyle) []color.RGBA {
+	palette := make([]color.RGBA, 0, len(slots))
+	for _, slot := range slots {
+		// 选择与背景对比度足够的颜色
+		col := pickGlyphColor(style, preset.ColorVariance, slotBackground(img, slot, baseline, style))
 
-	var noiseCount int
-	switch noiseLevel {
-	case Simple:
-		// Simple 级别几乎不做噪声处理
-		return
-	case Mid:
-		noiseCount = width * height / 50
-	case Hard:
-		noiseCount = width * height / 30
-	default:
-		noiseCount = width * height / 50
-	}
+		// 随机倾斜角度，在正负 MaxRotation 度之间
+		rad := randomRotation(preset)
 
-	for i := 0; i < noiseCount; i++ {
-		x := rand.Intn(width)
-		y := rand.Intn(height)
-		img.Set(x, y, color.RGBA{0, 0, 0, 255})
+		// 旋转字符
+		drawRotatedChar(img, slot.char, slot.x, baseline, rad, col, slot.face)
+		palette = append(palette, col)
 	}
+	return palette
 }
 
-// 添加干扰线
-func addLines(img *image.RGBA) {
-	width, height := img.Bounds().Dx(), img.Bounds().Dy()
-	rand.Seed(time.Now().UnixNano())
-	for i := 0; i < 5; i++ {
-		x1 := rand.Intn(width)
-		y1 := rand.Intn(height)
-		x2 := rand.Intn(width)
-		y2 := rand.Intn(height)
-		drawLine(img, x1, y1, x2, y2, color.RGBA{0, 0, 0, 255})
+// 字符所在区域的背景平均色，透明部分按页面背景色计算
+func slotBackground(img *image.RGBA, slot glyphSlot, baseline int, style renderStyle) color.RGBA {
+	metrics := slot.face.Metrics()
+	region := image.Rect(slot.x, baseline-metrics.Ascent.Ceil(), slot.x+slot.advance, baseline+metrics.Descent.Ceil())
+	if avg, ok := averageColor(img, region); ok {
+		return flattenColor(avg, style.pageColor)
 	}
+	return style.pageColor
+}
+
+// 随机倾斜角度（弧度），在正负 MaxRotation 度之间
+func randomRotation(preset NoisePreset) float64 {
+	angle := (rand.Float64()*2 - 1) * preset.MaxRotation
+	return angle * math.Pi / 180
 }
 
-// 绘制直线
-func drawLine(img *image.RGBA, x1, y1, x2, y2 int, col color.RGBA) {
-	dx := abs(x2 - x1)
-	dy := abs(y2 - y1)
-	sx, sy := 1, 1
-	if x1 >= x2 {
-		sx = -1
+// 绘制旋转字符，只旋转字形所在的包围盒，并以字形自身的中心为轴
+func drawRotatedChar(img *image.RGBA, char rune, x, y int, rad float64, col color.RGBA, face font.Face) {
+	dr, mask, _, _, ok := face.Glyph(fixed.P(x, y), char)
+	if !ok || dr.Empty() {
+		return
 	}
-	if y1 >= y2 {
-		sy = -1
+	alpha, ok := mask.(*image.Alpha)
+	if !ok {
+		return
 	}
-	err := dx - dy
 
-	for {
-		img.Set(x1, y1, col)
-		if x1 == x2 && y1 == y2 {
-			break
+	// 旋转字形遮罩，并保持包围盒中心不变
+	rotated := rotateMask(alpha, rad)
+	defer releaseMask(rotated)
+	offX := dr.Min.X + (dr.Dx()-rotated.Rect.Dx())/2
+	offY := dr.Min.Y + (dr.Dy()-rotated.Rect.Dy())/2
+
+	// 按覆盖率将字形颜色混合到背景上
+	for j := 0; j < rotated.Rect.Dy(); j++ {
+		row := rotated.Pix[j*rotated.Stride : j*rotated.Stride+rotated.Rect.Dx()]
+		for i, a := range row {
+			if a != 0 {
+				blendPixel(img, offX+i, offY+j, col, a)
+			}
 		}
-		e2 := err * 2
-		if e2 > -dy {
-			err -= dy
-			x1 += sx
+	}
+}
+
+// 以遮罩中心为轴旋转字形遮罩（双线性插值逆映射），返回的遮罩来自缓冲池
+//
+// 遮罩尺寸的奇偶性与原遮罩一致，保证旋转中心落在同一位置
+func rotateMask(src *image.Alpha, rad float64) *image.Alpha {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	sin, cos := math.Sincos(rad)
+
+	// 旋转后的包围盒
+	rw := int(math.Ceil(math.Abs(float64(w)*cos)+math.Abs(float64(h)*sin))) + 2
+	rh := int(math.Ceil(math.Abs(float64(w)*sin)+math.Abs(float64(h)*cos))) + 2
+	rw += (rw - w) & 1
+	rh += (rh - h) & 1
+	dst := newMask(rw, rh)
+
+	cx, cy := float64(w)/2, float64(h)/2
+	rcx, rcy := float64(rw)/2, float64(rh)/2
+	for y := 0; y < rh; y++ {
+		dy := float64(y) + 0.5 - rcy
+		for x := 0; x < rw; x++ {
+			dx := float64(x) + 0.5 - rcx
+			// 逆向旋转求源坐标（以像素中心为采样点）
+			sx := dx*cos + dy*sin + cx - 0.5
+			sy := -dx*sin + dy*cos + cy - 0.5
+			dst.Pix[y*dst.Stride+x] = sampleBilinear(src, sx, sy)
 		}
-		if e2 < dx {
-			err += dx
-			y1 += sy
+	}
+	return dst
+}
+
+// 双线性插值采样遮罩，越界部分视为透明
+func sampleBilinear(src *image.Alpha, fx, fy float64) uint8 {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if fx <= -1 || fy <= -1 || fx >= float64(w) || fy >= float64(h) {
+		return 0
+	}
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return 0
 		}
+		return float64(src.Pix[y*src.Stride+x])
 	}
+	top := at(x0, y0)*(1-tx) + at(x0+1, y0)*tx
+	bottom := at(x0, y0+1)*(1-tx) + at(x0+1, y0+1)*tx
+	return uint8(top*(1-ty) + bottom*ty + 0.5)
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
+// 按密度添加噪点，噪点边长随缩放倍数放大，覆盖面积比例保持不变
+func addNoise(img *image.RGBA, density float64, col color.RGBA, scale float64) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dot, noiseCount := noiseDots(width, height, density, scale)
+
+	for i := 0; i < noiseCount; i++ {
+		x, y := rand.Intn(width), rand.Intn(height)
+		for dy := 0; dy < dot; dy++ {
+			for dx := 0; dx < dot; dx++ {
+				setPixel(img, x+dx, y+dy, col)
+			}
+		}
 	}
-	return x
-}
\ No newline at end of file
+}
+
+// 计算噪点边长和数量
+func noiseDots(width, height int, density, scale float64) (dot, count int) {
+	dot = max(1, int(math.Round(scale)))
+	return dot, int(float64(width*height) * density / float64(dot*dot))
+}
@@ -0,0 +1,135 @@
+package captcha
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+func BenchmarkCreateCaptchaImage(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := createCaptchaImage("aB3xY9", Mid); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateCaptchaImageParallel(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := createCaptchaImage("aB3xY9", Mid); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// 对比每次解析字体与从缓存借用字体面的开销
+func BenchmarkFaceUncached(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			b.Fatal(err)
+		}
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: fontSize, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			b.Fatal(err)
+		}
+		face.Close()
+	}
+}
+
+func BenchmarkFaceCached(b *testing.B) {
+	f, err := loadDefaultFont()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		face, err := acquireFace(f, fontSize)
+		if err != nil {
+			b.Fatal(err)
+		}
+		releaseFace(f, fontSize, face)
+	}
+}
+
+func TestRotateMaskNoHoles(t *testing.T) {
+	src := newMask(20, 30)
+	for i := range src.Pix {
+		src.Pix[i] = 255
+	}
+
+	rotated := rotateMask(src, 10*math.Pi/180)
+	defer releaseMask(rotated)
+
+	// 旋转中心附近应完全不透明，不应出现最近邻正向映射留下的空洞
+	cx, cy := rotated.Rect.Dx()/2, rotated.Rect.Dy()/2
+	for y := cy - 8; y < cy+8; y++ {
+		for x := cx - 5; x < cx+5; x++ {
+			if a := rotated.AlphaAt(x, y).A; a != 255 {
+				t.Fatalf("(%d, %d) 的覆盖率应为255，实际为%d", x, y, a)
+			}
+		}
+	}
+}
+
+func TestBlendPixel(t *testing.T) {
+	img := newCanvas(1, 1)
+	fillRGBA(img, color.RGBA{255, 255, 255, 255})
+
+	blendPixel(img, 0, 0, color.RGBA{0, 0, 0, 255}, 128)
+	if got := img.RGBAAt(0, 0); got.R != 127 || got.A != 255 {
+		t.Fatalf("半覆盖的黑色混合到白色上应为灰色，实际为 %v", got)
+	}
+
+	// 半透明颜色按 color.RGBA 的约定为预乘颜色：{64,64,64,128} 是50%不透明的灰色128
+	fillRGBA(img, color.RGBA{255, 255, 255, 255})
+	blendPixel(img, 0, 0, color.RGBA{64, 64, 64, 128}, 255)
+	if got := img.RGBAAt(0, 0); got.R != 191 || got.A != 255 {
+		t.Fatalf("半透明灰色混合到白色上应为 191，实际为 %v", got)
+	}
+}
+
+func TestScaleFactor(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	base, err := createCaptchaImage("abcd", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	SetImageConfig(ImageConfig{Scale: 2})
+	retina, err := createCaptchaImage("abcd", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	bw, bh := base.Rect.Dx(), base.Rect.Dy()
+	rw, rh := retina.Rect.Dx(), retina.Rect.Dy()
+	if math.Abs(float64(rw)-2*float64(bw)) > 4 || math.Abs(float64(rh)-2*float64(bh)) > 4 {
+		t.Fatalf("2x 图片尺寸应约为 1x 的两倍，1x 为 %dx%d，2x 为 %dx%d", bw, bh, rw, rh)
+	}
+}
+
+func TestFixedSizeFitsLongCode(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	SetImageConfig(ImageConfig{Width: 120, Height: 40, Scale: 2})
+	img, err := createCaptchaImage("abcdefghijklmnop", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	if img.Rect.Dx() != 240 || img.Rect.Dy() != 80 {
+		t.Fatalf("图片尺寸应为 240x80，实际为 %dx%d", img.Rect.Dx(), img.Rect.Dy())
+	}
+
+	// 字符缩小后应完整落在画布内，左右边缘保持背景色
+	white := color.RGBA{255, 255, 255, 255}
+	for y := 0; y < img.Rect.Dy(); y++ {
+		if img.RGBAAt(0, y) != white || img.RGBAAt(img.Rect.Dx()-1, y) != white {
+			t.Fatalf("第 %d 行的边缘像素不是背景色，字符超出了画布", y)
+		}
+	}
+}
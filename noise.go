@@ -0,0 +1,126 @@
+package captcha
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// NoiseLevel 定义验证码难度级别，每个级别对应一个 NoisePreset
+type NoiseLevel string
+
+const (
+	Simple NoiseLevel = "simple" // 无噪点和干扰线，只有轻微倾斜
+	Mid    NoiseLevel = "mid"    // 适量噪点、干扰曲线和波浪扭曲
+	Hard   NoiseLevel = "hard"   // 密集噪点、粗干扰曲线和多重扭曲
+)
+
+// NoisePreset 难度预设，汇总一个难度级别的全部干扰参数
+type NoisePreset struct {
+	NoiseDensity  float64      // 噪点密度，噪点数占像素总数的比例
+	Curves        CurveOptions // 干扰曲线配置
+	Filters       []Filter     // 扭曲滤镜链
+	MaxRotation   float64      // 字符最大倾斜角度（度），实际角度在正负该值之间随机
+	ColorVariance float64      // 字符颜色的随机程度，0 为统一的深色，1 为完全随机
+}
+
+var (
+	noisePresets = map[NoiseLevel]NoisePreset{
+		Simple: {
+			MaxRotation:   10,
+			ColorVariance: 0.6,
+		},
+		Mid: {
+			NoiseDensity:  1.0 / 50,
+			Curves:        CurveOptions{Count: 3, MinWidth: 1, MaxWidth: 2, StrikeThrough: true},
+			Filters:       []Filter{WaveFilter{Amplitude: 2, Period: 36}},
+			MaxRotation:   15,
+			ColorVariance: 0.8,
+		},
+		Hard: {
+			NoiseDensity: 1.0 / 30,
+			Curves:       CurveOptions{Count: 5, MinWidth: 1.5, MaxWidth: 3, StrikeThrough: true},
+			Filters: []Filter{
+				WaveFilter{Amplitude: 3, Period: 28},
+				SwirlFilter{Strength: 0.5},
+				ElasticFilter{Alpha: 1.5, Sigma: 3},
+			},
+			MaxRotation:   25,
+			ColorVariance: 1,
+		},
+	}
+	presetLock sync.RWMutex
+)
+
+// RegisterNoiseLevel 注册自定义难度级别，也可用于覆盖内置级别的预设
+func RegisterNoiseLevel(level NoiseLevel, preset NoisePreset) error {
+	if level == "" {
+		return fmt.Errorf("noise level name must not be empty")
+	}
+	if preset.NoiseDensity < 0 || preset.NoiseDensity > 1 {
+		return fmt.Errorf("noise density must be between 0 and 1, got %v", preset.NoiseDensity)
+	}
+	if preset.ColorVariance < 0 || preset.ColorVariance > 1 {
+		return fmt.Errorf("color variance must be between 0 and 1, got %v", preset.ColorVariance)
+	}
+
+	presetLock.Lock()
+	defer presetLock.Unlock()
+	noisePresets[level] = preset
+	log.Info("Registered noise level %v: %+v", level, preset)
+	return nil
+}
+
+// NoiseLevels 返回所有已注册的难度级别
+func NoiseLevels() []NoiseLevel {
+	presetLock.RLock()
+	defer presetLock.RUnlock()
+
+	levels := make([]NoiseLevel, 0, len(noisePresets))
+	for level := range noisePresets {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+	return levels
+}
+
+// ParseNoiseLevel 解析难度级别名称，空字符串视为 Mid
+func ParseNoiseLevel(s string) (NoiseLevel, error) {
+	if _, err := lookupNoisePreset(NoiseLevel(s)); err != nil {
+		return "", err
+	}
+	if s == "" {
+		return Mid, nil
+	}
+	return NoiseLevel(s), nil
+}
+
+// 查找难度级别对应的预设，空级别视为 Mid
+func lookupNoisePreset(level NoiseLevel) (NoisePreset, error) {
+	if level == "" {
+		level = Mid
+	}
+
+	presetLock.RLock()
+	defer presetLock.RUnlock()
+	preset, ok := noisePresets[level]
+	if !ok {
+		return NoisePreset{}, fmt.Errorf("unknown noise level: %q", level)
+	}
+	return preset, nil
+}
+
+// 修改指定级别的预设，未注册的级别以 Mid 的预设为基础创建
+func updateNoisePreset(level NoiseLevel, update func(*NoisePreset)) {
+	presetLock.Lock()
+	defer presetLock.Unlock()
+
+	preset, ok := noisePresets[level]
+	if !ok {
+		preset = noisePresets[Mid]
+	}
+	update(&preset)
+	noisePresets[level] = preset
+}
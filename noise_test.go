@@ -0,0 +1,42 @@
+package captcha
+
+import "testing"
+
+func TestParseNoiseLevel(t *testing.T) {
+	if level, err := ParseNoiseLevel(""); err != nil || level != Mid {
+		t.Fatalf("空级别应解析为 Mid，实际为 %q, %v", level, err)
+	}
+	if _, err := ParseNoiseLevel("extreme"); err == nil {
+		t.Fatalf("未注册的级别应返回错误")
+	}
+}
+
+func TestRegisterNoiseLevel(t *testing.T) {
+	custom := NoiseLevel("test-custom")
+	err := RegisterNoiseLevel(custom, NoisePreset{
+		NoiseDensity:  0.1,
+		Curves:        CurveOptions{Count: 1, MinWidth: 1, MaxWidth: 1},
+		Filters:       []Filter{PerspectiveFilter{Strength: 0.05}},
+		MaxRotation:   5,
+		ColorVariance: 0,
+	})
+	if err != nil {
+		t.Fatalf("注册难度级别失败: %v", err)
+	}
+	if _, err := ParseNoiseLevel(string(custom)); err != nil {
+		t.Fatalf("已注册的级别应能解析: %v", err)
+	}
+	if _, err := createCaptchaImage("abcd", custom); err != nil {
+		t.Fatalf("使用自定义级别生成图片失败: %v", err)
+	}
+
+	if err := RegisterNoiseLevel("bad", NoisePreset{NoiseDensity: 2}); err == nil {
+		t.Fatalf("噪点密度超出范围时应返回错误")
+	}
+}
+
+func TestCreateCaptchaImageUnknownLevel(t *testing.T) {
+	if _, err := createCaptchaImage("abcd", "unknown"); err == nil {
+		t.Fatalf("未知难度级别应返回错误")
+	}
+}
@@ -0,0 +1,162 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// PhoneNumber 解析后的手机号
+type PhoneNumber struct {
+	CountryCode    int    // 国家/地区代码，如 86
+	NationalNumber string // 不含国家代码和长途前缀0的号码
+}
+
+// E164 返回 E.164 格式的号码，如 +8613800138000
+func (p PhoneNumber) E164() string {
+	return "+" + strconv.Itoa(p.CountryCode) + p.NationalNumber
+}
+
+func (p PhoneNumber) String() string {
+	return p.E164()
+}
+
+// PhoneConfig 手机号解析配置
+type PhoneConfig struct {
+	DefaultRegion string // 号码不带国家代码时使用的地区（ISO 3166 代码，如 "CN"、"US"），默认 "CN"
+}
+
+// ErrInvalidPhoneNumber 表示手机号格式不正确
+var ErrInvalidPhoneNumber = errors.New("invalid phone number")
+
+// 常用地区的国家代码
+var regionCountryCodes = map[string]int{
+	"CN": 86, "HK": 852, "MO": 853, "TW": 886,
+	"US": 1, "CA": 1, "GB": 44, "DE": 49, "FR": 33, "IT": 39, "ES": 34, "NL": 31, "RU": 7,
+	"JP": 81, "KR": 82, "SG": 65, "MY": 60, "TH": 66, "VN": 84, "ID": 62, "PH": 63,
+	"IN": 91, "AU": 61, "NZ": 64, "AE": 971, "SA": 966, "BR": 55, "MX": 52,
+}
+
+var (
+	// 中国大陆手机号段
+	cnMobilePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+	// 号码中允许出现的分隔符
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "\u00a0", "", "\u3000", "")
+
+	phoneConfig = PhoneConfig{DefaultRegion: "CN"}
+	phoneLock   sync.RWMutex
+)
+
+// SetPhoneConfig 设置手机号解析配置
+func SetPhoneConfig(config PhoneConfig) error {
+	if config.DefaultRegion == "" {
+		config.DefaultRegion = "CN"
+	}
+	config.DefaultRegion = strings.ToUpper(config.DefaultRegion)
+	if _, ok := regionCountryCodes[config.DefaultRegion]; !ok {
+		return fmt.Errorf("unsupported phone region: %q", config.DefaultRegion)
+	}
+
+	phoneLock.Lock()
+	defer phoneLock.Unlock()
+	phoneConfig = config
+	log.Info("Set phone config: default region=%s", config.DefaultRegion)
+	return nil
+}
+
+// ParsePhoneNumber 解析手机号
+//
+// 支持 "+86 138 0013 8000"、"008613800138000" 等国际格式，以及按 defaultRegion 解释的本地格式
+// （如 "138-0013-8000"）；中国大陆号码必须是有效的手机号段，其他地区按 E.164 的长度校验。
+func ParsePhoneNumber(raw, defaultRegion string) (PhoneNumber, error) {
+	s := phoneSeparators.Replace(strings.TrimSpace(raw))
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		s, international = s[1:], true
+	case strings.HasPrefix(s, "00"):
+		s, international = s[2:], true
+	}
+	if !isDigits(s) {
+		return PhoneNumber{}, fmt.Errorf("%w: %q", ErrInvalidPhoneNumber, raw)
+	}
+
+	var p PhoneNumber
+	if international {
+		countryCode, national, ok := splitCountryCode(s)
+		if !ok {
+			return PhoneNumber{}, fmt.Errorf("%w: %q", ErrInvalidPhoneNumber, raw)
+		}
+		p = PhoneNumber{CountryCode: countryCode, NationalNumber: trimTrunkPrefix(countryCode, national)}
+	} else {
+		countryCode, ok := regionCountryCodes[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return PhoneNumber{}, fmt.Errorf("unsupported phone region: %q", defaultRegion)
+		}
+		p = PhoneNumber{CountryCode: countryCode, NationalNumber: trimTrunkPrefix(countryCode, s)}
+	}
+
+	if p.CountryCode == 86 {
+		if !cnMobilePattern.MatchString(p.NationalNumber) {
+			return PhoneNumber{}, fmt.Errorf("%w: %q is not a mainland China mobile number", ErrInvalidPhoneNumber, raw)
+		}
+	} else if n := len(p.E164()) - 1; len(p.NationalNumber) < 4 || n > 15 {
+		return PhoneNumber{}, fmt.Errorf("%w: %q", ErrInvalidPhoneNumber, raw)
+	}
+	return p, nil
+}
+
+// 去掉国内号码的长途前缀0，如英国的 07911 123456 和国际格式中常见的 +44 (0)7911 123456；
+// 中国大陆手机号不带前缀，意大利的号码中0是号码本身的一部分，都保持不变
+func trimTrunkPrefix(countryCode int, national string) string {
+	if countryCode == 86 || countryCode == 39 {
+		return national
+	}
+	return strings.TrimPrefix(national, "0")
+}
+
+// NormalizePhoneNumber 按 SetPhoneConfig 配置的默认地区解析手机号，返回 E.164 格式
+func NormalizePhoneNumber(raw string) (string, error) {
+	phoneLock.RLock()
+	region := phoneConfig.DefaultRegion
+	phoneLock.RUnlock()
+
+	p, err := ParsePhoneNumber(raw, region)
+	if err != nil {
+		return "", err
+	}
+	return p.E164(), nil
+}
+
+// 从国际格式的数字中分离国家代码
+//
+// 按 ITU-T E.164 的编号规划：1 和 7 是一位代码，twoDigitCountryCodes 中的是两位代码，其余都是三位代码。
+func splitCountryCode(digits string) (int, string, bool) {
+	if len(digits) < 4 || digits[0] == '0' {
+		return 0, "", false
+	}
+	n := 3
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		n = 1
+	case twoDigitCountryCodes[digits[:2]]:
+		n = 2
+	}
+	code, _ := strconv.Atoi(digits[:n])
+	return code, digits[n:], true
+}
+
+// 所有两位的国家代码
+var twoDigitCountryCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true,
+	"39": true, "40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true,
+	"48": true, "49": true, "51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true, "64": true, "65": true,
+	"66": true, "81": true, "82": true, "84": true, "86": true, "90": true, "91": true, "92": true,
+	"93": true, "94": true, "95": true, "98": true,
+}
@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw, region, want string
+	}{
+		{"13800138000", "CN", "+8613800138000"},
+		{"138 0013 8000", "CN", "+8613800138000"},
+		{"+86 138-0013-8000", "CN", "+8613800138000"},
+		{"008613800138000", "US", "+8613800138000"},
+		{"(415) 555-0100", "US", "+14155550100"},
+		{"+1 415 555 0100", "CN", "+14155550100"},
+		{"07911 123456", "GB", "+447911123456"},
+		{"+852 9123 4567", "CN", "+85291234567"},
+		{"+44 7911 123456", "CN", "+447911123456"},
+		{"+44 (0)7911 123456", "CN", "+447911123456"},
+		{"0044 07911 123456", "US", "+447911123456"},
+		{"+39 06 1234 5678", "CN", "+390612345678"},
+		{"06 1234 5678", "IT", "+390612345678"},
+	}
+	for _, tt := range tests {
+		p, err := ParsePhoneNumber(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("ParsePhoneNumber(%q, %q) 返回错误: %v", tt.raw, tt.region, err)
+			continue
+		}
+		if p.E164() != tt.want {
+			t.Errorf("ParsePhoneNumber(%q, %q) = %s，期望 %s", tt.raw, tt.region, p.E164(), tt.want)
+		}
+	}
+
+	for _, raw := range []string{"", "12800138000", "1380013800", "+86 10 12345678", "138abc38000", "+0123456789", "+1234567890123456"} {
+		if _, err := ParsePhoneNumber(raw, "CN"); !errors.Is(err, ErrInvalidPhoneNumber) {
+			t.Errorf("ParsePhoneNumber(%q) 应返回 ErrInvalidPhoneNumber，实际为 %v", raw, err)
+		}
+	}
+}
+
+func TestPhoneNumberNormalizedKey(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+
+	if _, err := SendCaptchaToPhone("138 0013 8006", "T", `{"code":"{{.code1}}"}`, 6); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	msg, ok := fake.LastSentTo("13800138006")
+	if !ok || msg.Phone != "+8613800138006" {
+		t.Fatalf("服务商应收到 E.164 格式的号码: %+v", msg)
+	}
+	if !VerifyCode("+8613800138006", msg.Params["code"]) {
+		t.Fatalf("不同格式的同一号码应能验证通过")
+	}
+
+	if _, err := SendCaptchaToPhone("12345", "T", `{"code":"{{.code1}}"}`, 6); !errors.Is(err, ErrInvalidPhoneNumber) {
+		t.Fatalf("无效号码应返回 ErrInvalidPhoneNumber，实际为 %v", err)
+	}
+	if len(fake.Outbox()) != 1 {
+		t.Fatalf("无效号码不应发送短信")
+	}
+}
+
+func TestAliyunPhoneNumber(t *testing.T) {
+	if got := aliyunPhoneNumber("+8613800138000"); got != "13800138000" {
+		t.Fatalf("国内号码应去掉国家代码，实际为 %s", got)
+	}
+	if got := aliyunPhoneNumber("+85291234567"); got != "85291234567" {
+		t.Fatalf("国际号码应去掉 +，实际为 %s", got)
+	}
+}
@@ -0,0 +1,113 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+)
+
+var (
+	canvasPool sync.Pool // *[]uint8，RGBA 像素缓冲区
+	maskPool   sync.Pool // *[]uint8，字形遮罩缓冲区
+
+	// 复用 PNG 编码器内部缓冲区
+	pngEncoder = png.Encoder{BufferPool: &pngBufferPool{}}
+)
+
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	buf, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buf
+}
+
+func (p *pngBufferPool) Put(buf *png.EncoderBuffer) {
+	p.pool.Put(buf)
+}
+
+// 从缓冲池获取长度为 n 的字节切片，内容未清零
+func getPix(pool *sync.Pool, n int) []uint8 {
+	if buf, ok := pool.Get().(*[]uint8); ok && cap(*buf) >= n {
+		return (*buf)[:n]
+	}
+	return make([]uint8, n)
+}
+
+func putPix(pool *sync.Pool, pix []uint8) {
+	pix = pix[:0]
+	pool.Put(&pix)
+}
+
+// 创建画布，像素缓冲区来自缓冲池
+func newCanvas(width, height int) *image.RGBA {
+	return &image.RGBA{
+		Pix:    getPix(&canvasPool, width*height*4),
+		Stride: width * 4,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+}
+
+// 归还画布的像素缓冲区，归还后不能再使用该图片
+func releaseCanvas(img *image.RGBA) {
+	putPix(&canvasPool, img.Pix)
+}
+
+// 创建清零的遮罩，像素缓冲区来自缓冲池
+func newMask(width, height int) *image.Alpha {
+	pix := getPix(&maskPool, width*height)
+	clear(pix)
+	return &image.Alpha{
+		Pix:    pix,
+		Stride: width,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+}
+
+func releaseMask(mask *image.Alpha) {
+	putPix(&maskPool, mask.Pix)
+}
+
+// 用纯色填充整张图片
+func fillRGBA(img *image.RGBA, col color.RGBA) {
+	if len(img.Pix) == 0 {
+		return
+	}
+	width := img.Rect.Dx()
+	row := img.Pix[:width*4]
+	for i := 0; i < len(row); i += 4 {
+		row[i], row[i+1], row[i+2], row[i+3] = col.R, col.G, col.B, col.A
+	}
+	for y := 1; y < img.Rect.Dy(); y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+width*4], row)
+	}
+}
+
+// 设置单个像素，越界时忽略
+func setPixel(img *image.RGBA, x, y int, col color.RGBA) {
+	if !(image.Point{x, y}.In(img.Rect)) {
+		return
+	}
+	o := img.PixOffset(x, y)
+	pix := img.Pix[o : o+4 : o+4]
+	pix[0], pix[1], pix[2], pix[3] = col.R, col.G, col.B, col.A
+}
+
+// 以覆盖率 a 将颜色 col 按 source-over 方式混合到像素上，越界时忽略
+func blendPixel(img *image.RGBA, x, y int, col color.RGBA, a uint8) {
+	if !(image.Point{x, y}.In(img.Rect)) {
+		return
+	}
+	o := img.PixOffset(x, y)
+	pix := img.Pix[o : o+4 : o+4]
+
+	// col 与 color.RGBA 的约定一致，为预乘颜色，只需再乘以覆盖率
+	sa := uint32(a) * uint32(col.A) / 255
+	inv := 255 - sa
+	pix[0] = uint8((uint32(col.R)*uint32(a) + uint32(pix[0])*inv) / 255)
+	pix[1] = uint8((uint32(col.G)*uint32(a) + uint32(pix[1])*inv) / 255)
+	pix[2] = uint8((uint32(col.B)*uint32(a) + uint32(pix[2])*inv) / 255)
+	pix[3] = uint8(sa + uint32(pix[3])*inv/255)
+}
@@ -0,0 +1,91 @@
+package captcha
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+const (
+	DefaultPoWDifficulty = 20 // 默认难度，普通设备约需百万次哈希
+	MaxPoWDifficulty     = 26 // 允许的最大难度，浏览器中约需数分钟，更高的难度在有效期内难以求解
+)
+
+// 默认难度及以下的挑战有效期为60秒，难度每增加一位，求解所需的哈希次数翻倍，有效期也随之翻倍
+func powTTL(difficulty int) time.Duration {
+	if difficulty <= DefaultPoWDifficulty {
+		return defaultCaptchaTTL
+	}
+	return defaultCaptchaTTL << (difficulty - DefaultPoWDifficulty)
+}
+
+// GetPoW 生成一个工作量证明挑战，返回验证码ID和随机前缀
+//
+// 客户端需要找到一个 nonce，使 SHA-256(prefix + nonce) 至少有 difficulty 个前导零位，
+// 然后以 nonce 作为用户输入调用 Verify，验证通过后挑战即失效。对可疑客户端可以调高 difficulty 增加其计算成本，
+// 超过 DefaultPoWDifficulty 时有效期随难度加长。
+func GetPoW(difficulty int) (string, string, error) {
+	log.Info("GetPoW called with difficulty: %d", difficulty)
+
+	if difficulty <= 0 || difficulty > MaxPoWDifficulty {
+		log.Error("Invalid PoW difficulty: %d", difficulty)
+		return "", "", fmt.Errorf("pow difficulty must be between 1 and %d, got %d", MaxPoWDifficulty, difficulty)
+	}
+
+	// 生成随机前缀
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Error("Failed to generate PoW prefix: %v", err)
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(buf)
+	log.Info("Generated PoW prefix: %s", prefix)
+
+	captchaID := generateCaptchaID()
+	ttl := powTTL(difficulty)
+	storeCaptchaInfo(captchaID, &CaptchaInfo{
+		Type:       TypePoW,
+		Code:       prefix,
+		Difficulty: difficulty,
+		ExpiresAt:  time.Now().Add(ttl),
+	})
+	expireCaptchaAfter(captchaID, ttl)
+
+	return captchaID, prefix, nil
+}
+
+// SolvePoW 暴力求解工作量证明挑战，返回满足难度的 nonce，主要用于测试和 Go 客户端
+func SolvePoW(prefix string, difficulty int) string {
+	for n := uint64(0); ; n++ {
+		nonce := strconv.FormatUint(n, 10)
+		if checkPoW(prefix, nonce, difficulty) {
+			return nonce
+		}
+	}
+}
+
+// 检查 SHA-256(prefix + nonce) 是否至少有 difficulty 个前导零位
+func checkPoW(prefix, nonce string, difficulty int) bool {
+	if nonce == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(prefix + nonce))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
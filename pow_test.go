@@ -0,0 +1,72 @@
+package captcha
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoWVerify(t *testing.T) {
+	captchaID, prefix, err := GetPoW(8)
+	if err != nil {
+		t.Fatalf("生成工作量证明挑战失败: %v", err)
+	}
+
+	nonce := SolvePoW(prefix, 8)
+	if !Verify(captchaID, nonce) {
+		t.Fatalf("正确的 nonce 未通过验证")
+	}
+	if Verify(captchaID, nonce) {
+		t.Fatalf("验证通过后 nonce 不应能再次使用")
+	}
+
+	captchaID, _, err = GetPoW(8)
+	if err != nil {
+		t.Fatalf("生成工作量证明挑战失败: %v", err)
+	}
+	if Verify(captchaID, "") {
+		t.Fatalf("空 nonce 不应通过验证")
+	}
+}
+
+func TestPoWTTLScalesWithDifficulty(t *testing.T) {
+	if got := powTTL(8); got != defaultCaptchaTTL {
+		t.Fatalf("低难度的有效期应为 %v，实际为 %v", defaultCaptchaTTL, got)
+	}
+	if got := powTTL(DefaultPoWDifficulty + 2); got != 4*defaultCaptchaTTL {
+		t.Fatalf("难度增加2位时有效期应为4倍，实际为 %v", got)
+	}
+
+	captchaID, _, err := GetPoW(MaxPoWDifficulty)
+	if err != nil {
+		t.Fatalf("生成工作量证明挑战失败: %v", err)
+	}
+	info := getCaptcha(captchaID)
+	if remaining := time.Until(info.ExpiresAt); remaining < powTTL(MaxPoWDifficulty)-time.Second {
+		t.Fatalf("最高难度的挑战有效期过短: %v", remaining)
+	}
+}
+
+func TestGetPoWInvalidDifficulty(t *testing.T) {
+	if _, _, err := GetPoW(0); err == nil {
+		t.Fatalf("难度为0时应返回错误")
+	}
+	if _, _, err := GetPoW(MaxPoWDifficulty + 1); err == nil {
+		t.Fatalf("难度超出上限时应返回错误")
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	cases := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x00, 0x10}, 11},
+		{[]byte{0x00, 0x00}, 16},
+	}
+	for _, c := range cases {
+		if got := leadingZeroBits(c.in); got != c.want {
+			t.Errorf("leadingZeroBits(%x) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// OTPPurpose 验证码的用途，不同用途的验证码分开存储，不能互相使用
+type OTPPurpose string
+
+const (
+	PurposeLogin         OTPPurpose = "login"          // 登录
+	PurposeRegister      OTPPurpose = "register"       // 注册
+	PurposeResetPassword OTPPurpose = "reset_password" // 重置密码
+	PurposeBindPhone     OTPPurpose = "bind_phone"     // 绑定手机号
+)
+
+// PurposeConfig 某个用途的验证码配置
+type PurposeConfig struct {
+	Template SMSTemplate            // 短信模板
+	Locales  map[string]SMSTemplate // 按语言区分的短信模板，key 为 BCP 47 标签（如 "en"、"zh-TW"），用 WithLocale 选择
+	TTL      time.Duration          // 验证码有效期，默认60秒
+	Limit    *SMSLimitConfig        // 该用途单独的发送限制，与 SetSMSLimitConfig 的全局限制同时生效；为 nil 时只受全局限制
+}
+
+// ErrUnknownPurpose 表示用途未通过 SetPurposeConfig 配置
+var ErrUnknownPurpose = errors.New("unknown OTP purpose")
+
+var (
+	purposeConfigs = map[OTPPurpose]PurposeConfig{}
+	purposeLock    sync.RWMutex
+)
+
+// SetPurposeConfig 设置某个用途的短信模板、有效期和发送限制，模板在此时校验
+func SetPurposeConfig(purpose OTPPurpose, config PurposeConfig) error {
+	if purpose == "" {
+		return errors.New("OTP purpose must not be empty")
+	}
+	if err := config.Template.Validate(); err != nil {
+		return fmt.Errorf("OTP purpose %q: %w", purpose, err)
+	}
+	for locale, tpl := range config.Locales {
+		if normalizeLocale(locale) == "" {
+			return fmt.Errorf("OTP purpose %q: empty locale", purpose)
+		}
+		if err := tpl.Validate(); err != nil {
+			return fmt.Errorf("OTP purpose %q, locale %s: %w", purpose, locale, err)
+		}
+	}
+	if config.TTL < 0 {
+		return fmt.Errorf("OTP purpose %q has negative TTL", purpose)
+	}
+	if config.Limit != nil {
+		limit := *config.Limit
+		if limit.Store == nil {
+			limit.Store = NewMemoryCounterStore()
+		}
+		config.Limit = &limit
+	}
+
+	purposeLock.Lock()
+	defer purposeLock.Unlock()
+	purposeConfigs[purpose] = config
+	log.Info("Set OTP purpose config: %s, template=%s, locales=%d, ttl=%v", purpose, config.Template.TemplateCode, len(config.Locales), config.TTL)
+	return nil
+}
+
+func lookupPurposeConfig(purpose OTPPurpose) (PurposeConfig, error) {
+	purposeLock.RLock()
+	defer purposeLock.RUnlock()
+	config, ok := purposeConfigs[purpose]
+	if !ok {
+		return PurposeConfig{}, fmt.Errorf("%w: %q", ErrUnknownPurpose, purpose)
+	}
+	return config, nil
+}
+
+// SendPhoneOTP 发送指定用途的手机验证码，使用该用途配置的短信模板、有效期和发送限制
+//
+// ctx 中用 WithLocale 记录了用户语言时选择对应的本地化模板；验证时必须用 VerifyPhoneOTP 并传入相同的用途。
+func SendPhoneOTP(ctx context.Context, purpose OTPPurpose, phoneNumber string, captchaLength int) (string, error) {
+	config, err := lookupPurposeConfig(purpose)
+	if err != nil {
+		log.Error("Failed to get OTP purpose config: %v", err)
+		return "", err
+	}
+	locale, _ := LocaleFromContext(ctx)
+	return sendPhoneCaptcha(ctx, purpose, config, phoneNumber, selectSMSTemplate(config, locale), captchaLength)
+}
+
+// VerifyPhoneOTP 验证指定用途的手机验证码，验证成功后验证码失效
+func VerifyPhoneOTP(purpose OTPPurpose, phoneNumber, userInputCode string) bool {
+	if _, err := lookupPurposeConfig(purpose); err != nil {
+		log.Warn("Failed to verify OTP: %v", err)
+		return false
+	}
+	return verifyPhoneCaptcha(purpose, phoneNumber, userInputCode)
+}
+
+// 验证码的存储键，不带用途时为手机号本身，与 SendCaptchaToPhone 和 VerifyCode 兼容
+func otpKey(purpose OTPPurpose, phoneNumber string) string {
+	if purpose == "" {
+		return phoneNumber
+	}
+	return "otp:" + string(purpose) + ":" + phoneNumber
+}
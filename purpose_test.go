@@ -0,0 +1,87 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 清空用途配置，测试结束时恢复
+func resetPurposeConfigs(t *testing.T) {
+	purposeLock.Lock()
+	saved := purposeConfigs
+	purposeConfigs = map[OTPPurpose]PurposeConfig{}
+	purposeLock.Unlock()
+	t.Cleanup(func() {
+		purposeLock.Lock()
+		purposeConfigs = saved
+		purposeLock.Unlock()
+	})
+}
+
+func TestPhoneOTPPurposesAreIsolated(t *testing.T) {
+	resetSMSProviders(t)
+	resetPurposeConfigs(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	SetPurposeConfig(PurposeRegister, PurposeConfig{Template: SMSTemplate{TemplateCode: "SMS_REGISTER"}})
+	SetPurposeConfig(PurposeResetPassword, PurposeConfig{Template: SMSTemplate{TemplateCode: "SMS_RESET"}, TTL: 30 * time.Millisecond})
+
+	ctx := context.Background()
+	registerCode, err := SendPhoneOTP(ctx, PurposeRegister, "13800138010", 6)
+	if err != nil {
+		t.Fatalf("发送注册验证码失败: %v", err)
+	}
+	if msg, _ := fake.LastSentTo("13800138010"); msg.TemplateID != "SMS_REGISTER" {
+		t.Fatalf("应使用注册用途的模板，实际为 %s", msg.TemplateID)
+	}
+	resetCode, err := SendPhoneOTP(ctx, PurposeResetPassword, "13800138010", 6)
+	if err != nil {
+		t.Fatalf("发送重置密码验证码失败: %v", err)
+	}
+
+	// 注册验证码不能用于重置密码，也不能用 VerifyCode 验证
+	if registerCode != resetCode && VerifyPhoneOTP(PurposeResetPassword, "13800138010", registerCode) {
+		t.Fatalf("注册验证码不应能用于重置密码")
+	}
+	if VerifyCode("13800138010", registerCode) {
+		t.Fatalf("按用途发送的验证码不应能用 VerifyCode 验证")
+	}
+	// 发送重置密码验证码不会覆盖注册验证码
+	if !VerifyPhoneOTP(PurposeRegister, "13800138010", registerCode) {
+		t.Fatalf("注册验证码应能验证通过")
+	}
+
+	// 重置密码验证码按该用途的有效期过期
+	time.Sleep(40 * time.Millisecond)
+	if VerifyPhoneOTP(PurposeResetPassword, "13800138010", resetCode) {
+		t.Fatalf("过期的重置密码验证码不应验证通过")
+	}
+
+	if _, err := SendPhoneOTP(ctx, PurposeLogin, "13800138010", 6); !errors.Is(err, ErrUnknownPurpose) {
+		t.Fatalf("未配置的用途应返回 ErrUnknownPurpose，实际为 %v", err)
+	}
+}
+
+func TestPhoneOTPPurposeLimit(t *testing.T) {
+	resetSMSProviders(t)
+	resetPurposeConfigs(t)
+	RegisterSMSProvider("fake", NewFakeSMSProvider())
+	SetPurposeConfig(PurposeLogin, PurposeConfig{Template: SMSTemplate{TemplateCode: "SMS_LOGIN"}, Limit: &SMSLimitConfig{PhoneDaily: 1}})
+	SetPurposeConfig(PurposeBindPhone, PurposeConfig{Template: SMSTemplate{TemplateCode: "SMS_BIND"}})
+
+	ctx := context.Background()
+	if _, err := SendPhoneOTP(ctx, PurposeLogin, "13800138011", 6); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	_, err := SendPhoneOTP(ctx, PurposeLogin, "13800138011", 6)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != "phone_daily" {
+		t.Fatalf("超出登录用途的每日次数应返回 phone_daily 限制，实际为 %v", err)
+	}
+	// 其他用途不受登录用途的限制
+	if _, err := SendPhoneOTP(ctx, PurposeBindPhone, "13800138011", 6); err != nil {
+		t.Fatalf("其他用途发送失败: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// WritableFS 可写文件系统，GetAndSave 通过它保存验证码图片
+//
+// name 为 io/fs 风格的斜杠分隔相对路径（已通过 fs.ValidPath 校验）。
+// 实现需保证写入是原子的：读取方要么看不到文件，要么看到完整的内容，
+// 对象存储等后端可直接用一次上传实现。
+type WritableFS interface {
+	WriteFile(name string, data []byte) error
+}
+
+// SaveConfig 验证码图片的保存配置
+type SaveConfig struct {
+	Dir string     // 保存目录，GetAndSave 的路径相对于该目录，且不能越出该目录
+	FS  WritableFS // 自定义可写文件系统，优先于 Dir
+}
+
+var (
+	// ErrSaveNotConfigured 表示尚未调用 SetSaveConfig
+	ErrSaveNotConfigured = errors.New("captcha save is not configured")
+	// ErrInvalidSavePath 表示保存路径为绝对路径或越出了保存目录
+	ErrInvalidSavePath = errors.New("invalid captcha save path")
+
+	saveFS   WritableFS
+	saveLock sync.RWMutex
+)
+
+// SetSaveConfig 设置验证码图片的保存目录或可写文件系统
+func SetSaveConfig(config SaveConfig) error {
+	fsys := config.FS
+	if fsys == nil {
+		if config.Dir == "" {
+			return errors.New("captcha save requires Dir or FS")
+		}
+		fsys = DirFS(config.Dir)
+	}
+
+	saveLock.Lock()
+	defer saveLock.Unlock()
+	saveFS = fsys
+	log.Info("Set save config: dir=%q, custom FS=%v", config.Dir, config.FS != nil)
+	return nil
+}
+
+// 清理保存路径，拒绝绝对路径和越出保存目录的路径
+func cleanSavePath(savePath string) (string, error) {
+	name := path.Clean(filepath.ToSlash(savePath))
+	if filepath.IsAbs(savePath) || filepath.VolumeName(savePath) != "" || name == "." || !fs.ValidPath(name) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSavePath, savePath)
+	}
+	return name, nil
+}
+
+// 通过配置的文件系统保存文件
+func saveFile(savePath string, data []byte) error {
+	saveLock.RLock()
+	fsys := saveFS
+	saveLock.RUnlock()
+	if fsys == nil {
+		return ErrSaveNotConfigured
+	}
+
+	name, err := cleanSavePath(savePath)
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(name, data)
+}
+
+// DirFS 返回以 dir 为根目录的可写文件系统
+//
+// 文件先写入同一目录下的临时文件，再重命名为目标文件，保证写入是原子的；
+// 目录逐级创建，每一级经符号链接解析后都必须位于根目录内，否则在创建任何内容之前返回 ErrInvalidSavePath。
+func DirFS(dir string) WritableFS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+// WriteFile 实现 WritableFS 接口
+func (d dirFS) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := filepath.Abs(string(d))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	// 逐级创建目录，每一级都先解析符号链接并确认仍在根目录内再继续，
+	// 防止通过目录中的符号链接在根目录之外创建目录或写入文件
+	dir := realRoot
+	parts := strings.Split(name, "/")
+	for _, part := range parts[:len(parts)-1] {
+		next := filepath.Join(dir, part)
+		if err := os.Mkdir(next, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		realNext, err := filepath.EvalSymlinks(next)
+		if err != nil {
+			return err
+		}
+		if !withinDir(realRoot, realNext) {
+			return &fs.PathError{Op: "write", Path: name, Err: ErrInvalidSavePath}
+		}
+		dir = realNext
+	}
+	target := filepath.Join(dir, parts[len(parts)-1])
+
+	tmp, err := os.CreateTemp(dir, ".captcha-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 重命名成功后删除会失败，可以忽略
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// 判断已解析符号链接的路径 path 是否位于 root 内
+func withinDir(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// MemFS 内存中的可写文件系统，适用于测试或由调用方自行上传的场景
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemFS 创建一个空的内存文件系统
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string][]byte)}
+}
+
+// WriteFile 实现 WritableFS 接口
+func (m *MemFS) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+// ReadFile 读取已保存的文件
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Names 返回所有已保存文件的路径
+func (m *MemFS) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 为 HTTP 接口随机生成保存文件名，扩展名与配置的输出格式一致
+func randomSaveName() string {
+	ext := FormatPNG.Extension()
+	if style, err := currentRenderStyle(); err == nil {
+		ext = style.format.Extension()
+	}
+	return generateCaptchaID() + ext
+}
@@ -0,0 +1,91 @@
+package captcha
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanSavePath(t *testing.T) {
+	for _, p := range []string{"../x.png", "/etc/passwd", "a/../../x.png", "..", "", "."} {
+		if _, err := cleanSavePath(p); !errors.Is(err, ErrInvalidSavePath) {
+			t.Fatalf("路径 %q 应被拒绝，实际错误为 %v", p, err)
+		}
+	}
+	if name, err := cleanSavePath("./a/b/../c.png"); err != nil || name != "a/c.png" {
+		t.Fatalf("路径应清理为 a/c.png，实际为 %q, %v", name, err)
+	}
+}
+
+func TestGetAndSaveMemFS(t *testing.T) {
+	defer func() { saveFS = nil }()
+
+	if _, _, err := GetAndSave(4, Numeric, Simple, "x.png"); !errors.Is(err, ErrSaveNotConfigured) {
+		t.Fatalf("未配置时应返回 ErrSaveNotConfigured，实际为 %v", err)
+	}
+
+	mem := NewMemFS()
+	if err := SetSaveConfig(SaveConfig{FS: mem}); err != nil {
+		t.Fatalf("设置保存配置失败: %v", err)
+	}
+	if _, _, err := GetAndSave(4, Numeric, Simple, "../escape.png"); !errors.Is(err, ErrInvalidSavePath) {
+		t.Fatalf("越出目录的路径应被拒绝，实际为 %v", err)
+	}
+	id, code, err := GetAndSave(4, Numeric, Simple, "captchas/a.png")
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if data, err := mem.ReadFile("captchas/a.png"); err != nil || len(data) == 0 {
+		t.Fatalf("内存文件系统中应有保存的图片: %v", err)
+	}
+	if !Verify(id, code) {
+		t.Fatalf("保存后的验证码应能通过验证")
+	}
+}
+
+func TestDirFSAtomicWrite(t *testing.T) {
+	root := t.TempDir()
+	fsys := DirFS(root)
+	if err := fsys.WriteFile("sub/a.png", []byte("data")); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "sub", "a.png")); err != nil || string(data) != "data" {
+		t.Fatalf("文件内容错误: %q, %v", data, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "sub"))
+	if len(entries) != 1 {
+		t.Fatalf("不应残留临时文件，目录中有 %d 个文件", len(entries))
+	}
+
+	// 目录中指向根目录之外的符号链接不能被用来越出根目录
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+	if err := fsys.WriteFile("link/b.png", []byte("x")); !errors.Is(err, ErrInvalidSavePath) {
+		t.Fatalf("经符号链接越出根目录应被拒绝，实际为 %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "b.png")); !os.IsNotExist(err) {
+		t.Fatalf("根目录之外不应出现文件")
+	}
+
+	// 经符号链接的子目录也不能在根目录之外创建目录
+	if err := fsys.WriteFile("link/newdir/c.png", []byte("x")); !errors.Is(err, ErrInvalidSavePath) {
+		t.Fatalf("经符号链接创建子目录应被拒绝，实际为 %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "newdir")); !os.IsNotExist(err) {
+		t.Fatalf("根目录之外不应创建目录")
+	}
+
+	// 指向根目录内的符号链接可以正常使用
+	if err := os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "inner")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+	if err := fsys.WriteFile("inner/deep/d.png", []byte("y")); err != nil {
+		t.Fatalf("经根目录内的符号链接写入失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "sub", "deep", "d.png")); err != nil {
+		t.Fatalf("文件应写入链接指向的目录: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofiber/fiber/v2"
 )
 
 // Service 处理生成验证码的请求
@@ -12,7 +13,9 @@ func GinService(c *gin.Context) {
 	// 从请求中获取参数
 	lengthStr := c.DefaultQuery("length", "6")
 	formatStr := c.DefaultQuery("format", "numeric")
-	savePath := c.DefaultQuery("savePath", "")
+	levelStr := c.DefaultQuery("level", string(Mid))
+	output := c.DefaultQuery("output", "base64")
+	save := c.DefaultQuery("save", "") == "true"
 
 	// 解析验证码长度
 	length, err := strconv.Atoi(lengthStr)
@@ -33,19 +36,48 @@ func GinService(c *gin.Context) {
 		return
 	}
 
-	// 根据 savePath 参数决定调用哪个函数
+	// 解析难度级别
+	level, err := ParseNoiseLevel(levelStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level parameter"})
+		return
+	}
+
+	// output=raw 时直接输出图片，验证码ID放在响应头中
+	if output == "raw" {
+		stream, err := GetStream(length, format, level)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("X-Captcha-Id", stream.ID)
+		c.Header("Content-Type", stream.MIMEType())
+		c.Status(http.StatusOK)
+		stream.WriteTo(c.Writer)
+		return
+	}
+
+	// 根据 save 参数决定调用哪个函数
 	var captchaID, captchaData string
-	var img image.Image
-	if savePath != "" {
-		// 生成验证码并保存到指定路径
-		captchaID, captchaData, err = GetAndSave(length, format, savePath)
+	if save {
+		// 生成验证码并保存到 SetSaveConfig 配置的目录，文件名由服务端生成，不接受客户端指定的路径；
+		// 返回文件名而不是验证码内容
+		captchaData = randomSaveName()
+		captchaID, _, err = GetAndSave(length, format, level, captchaData)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else if output == "datauri" {
+		// 生成验证码并返回 data URI
+		captchaID, captchaData, err = GetDataURI(length, format, level)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 	} else {
 		// 生成验证码并返回 base64 编码的图片
-		captchaID, captchaData, err = GetBase64(length, format)
+		captchaID, captchaData, err = GetBase64(length, format, level)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -68,7 +100,9 @@ func FiberService(c *fiber.Ctx) error {
 	// 从请求中获取参数
 	lengthStr := c.Query("length", "6")
 	formatStr := c.Query("format", "numeric")
-	savePath := c.Query("savePath", "")
+	levelStr := c.Query("level", string(Mid))
+	output := c.Query("output", "base64")
+	save := c.Query("save", "") == "true"
 
 	// 解析验证码长度
 	length, err := strconv.Atoi(lengthStr)
@@ -87,18 +121,43 @@ func FiberService(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid format parameter"})
 	}
 
-	// 根据 savePath 参数决定调用哪个函数
+	// 解析难度级别
+	level, err := ParseNoiseLevel(levelStr)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid level parameter"})
+	}
+
+	// output=raw 时直接输出图片，验证码ID放在响应头中
+	if output == "raw" {
+		stream, err := GetStream(length, format, level)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		c.Set("X-Captcha-Id", stream.ID)
+		c.Set(fiber.HeaderContentType, stream.MIMEType())
+		_, err = stream.WriteTo(c)
+		return err
+	}
+
+	// 根据 save 参数决定调用哪个函数
 	var captchaID, captchaData string
-	var img image.Image
-	if savePath != "" {
-		// 生成验证码并保存到指定路径
-		captchaID, captchaData, err = GetAndSave(length, format, savePath)
+	if save {
+		// 生成验证码并保存到 SetSaveConfig 配置的目录，文件名由服务端生成，不接受客户端指定的路径；
+		// 返回文件名而不是验证码内容
+		captchaData = randomSaveName()
+		captchaID, _, err = GetAndSave(length, format, level, captchaData)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else if output == "datauri" {
+		// 生成验证码并返回 data URI
+		captchaID, captchaData, err = GetDataURI(length, format, level)
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
 	} else {
 		// 生成验证码并返回 base64 编码的图片
-		captchaID, captchaData, err = GetBase64(length, format)
+		captchaID, captchaData, err = GetBase64(length, format, level)
 		if err != nil {
 			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -0,0 +1,87 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// SMSProvider 短信服务商，手机验证码只通过该接口发送短信
+//
+// params 为模板变量，由服务商按自己的协议编码（如阿里云编码为 JSON）；返回服务商的消息ID。
+type SMSProvider interface {
+	Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error)
+}
+
+// ErrNoSMSProvider 表示没有可用的短信服务商
+var ErrNoSMSProvider = errors.New("no SMS provider configured")
+
+var (
+	smsProviders       = map[string]SMSProvider{}
+	defaultSMSProvider string
+	smsLock            sync.RWMutex
+)
+
+// RegisterSMSProvider 注册短信服务商，也可覆盖同名服务商；第一个注册的服务商成为默认服务商
+func RegisterSMSProvider(name string, provider SMSProvider) error {
+	if name == "" {
+		return errors.New("SMS provider name must not be empty")
+	}
+	if provider == nil {
+		return fmt.Errorf("SMS provider %q must not be nil", name)
+	}
+
+	smsLock.Lock()
+	defer smsLock.Unlock()
+	smsProviders[name] = provider
+	if defaultSMSProvider == "" {
+		defaultSMSProvider = name
+	}
+	log.Info("Registered SMS provider: %s", name)
+	return nil
+}
+
+// SetDefaultSMSProvider 设置发送手机验证码时使用的服务商
+func SetDefaultSMSProvider(name string) error {
+	smsLock.Lock()
+	defer smsLock.Unlock()
+	if _, ok := smsProviders[name]; !ok {
+		return fmt.Errorf("unknown SMS provider: %q", name)
+	}
+	defaultSMSProvider = name
+	log.Info("Set default SMS provider: %s", name)
+	return nil
+}
+
+// SMSProviders 返回所有已注册的服务商名称
+func SMSProviders() []string {
+	smsLock.RLock()
+	defer smsLock.RUnlock()
+	names := make([]string, 0, len(smsProviders))
+	for name := range smsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 查找服务商，name 为空时使用默认服务商
+func lookupSMSProvider(name string) (SMSProvider, error) {
+	smsLock.RLock()
+	defer smsLock.RUnlock()
+	if name == "" {
+		name = defaultSMSProvider
+	}
+	if name == "" {
+		return nil, ErrNoSMSProvider
+	}
+	provider, ok := smsProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown SMS provider: %q", name)
+	}
+	return provider, nil
+}
@@ -0,0 +1,114 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/dysmsapi"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// AliyunConfig 存储阿里云短信服务配置
+type AliyunConfig struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SignName        string
+	RegionID        string // 地域，默认 cn-hangzhou
+}
+
+// SetAliyunConfig 设置阿里云短信服务配置，并以 "aliyun" 为名注册阿里云短信服务商
+func SetAliyunConfig(config AliyunConfig) {
+	if err := RegisterSMSProvider("aliyun", NewAliyunProvider(config)); err != nil {
+		log.Error("Failed to register Aliyun SMS provider: %v", err)
+	}
+}
+
+// AliyunProvider 阿里云短信服务商，客户端在第一次发送时创建并复用
+type AliyunProvider struct {
+	config AliyunConfig
+
+	mu     sync.Mutex
+	client *dysmsapi.Client
+}
+
+// NewAliyunProvider 创建阿里云短信服务商
+func NewAliyunProvider(config AliyunConfig) *AliyunProvider {
+	if config.RegionID == "" {
+		config.RegionID = "cn-hangzhou"
+	}
+	return &AliyunProvider{config: config}
+}
+
+// 创建或复用阿里云客户端，创建失败时下次发送会重试
+func (p *AliyunProvider) getClient() (*dysmsapi.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client != nil {
+		return p.client, nil
+	}
+	client, err := dysmsapi.NewClientWithAccessKey(p.config.RegionID, p.config.AccessKeyID, p.config.AccessKeySecret)
+	if err != nil {
+		log.Error("Failed to create Aliyun client: %v", err)
+		return nil, err
+	}
+	p.client = client
+	return client, nil
+}
+
+// Send 实现 SMSProvider 接口，模板变量编码为 JSON 作为 TemplateParam
+func (p *AliyunProvider) Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error) {
+	client, err := p.getClient()
+	if err != nil {
+		return "", err
+	}
+	templateParam, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	request := dysmsapi.CreateSendSmsRequest()
+	request.Scheme = "https"
+	request.PhoneNumbers = aliyunPhoneNumber(phone)
+	request.SignName = p.config.SignName
+	request.TemplateCode = templateID
+	request.TemplateParam = string(templateParam)
+
+	// SDK 不支持 context，在单独的 goroutine 中发送，context 取消时直接返回
+	type result struct {
+		response *dysmsapi.SendSmsResponse
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := client.SendSms(request)
+		done <- result{response, err}
+	}()
+
+	var r result
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r = <-done:
+	}
+	if r.err != nil {
+		log.Error("Failed to send SMS: %v", r.err)
+		return "", r.err
+	}
+	if r.response.Code != "OK" {
+		log.Error("Failed to send SMS: %s", r.response.Message)
+		return "", fmt.Errorf("failed to send SMS: %s", r.response.Message)
+	}
+	return r.response.BizId, nil
+}
+
+// 阿里云要求国内号码不带国家代码，国际/港澳台号码为国家代码加号码且不带 "+"
+func aliyunPhoneNumber(phone string) string {
+	if national, ok := strings.CutPrefix(phone, "+86"); ok {
+		return national
+	}
+	return strings.TrimPrefix(phone, "+")
+}
@@ -0,0 +1,242 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// WeightedSMSProvider 路由中的一个服务商
+type WeightedSMSProvider struct {
+	Name     string // 服务商名称，用于熔断统计和投递记录，同一组合服务商内不能重复
+	Provider SMSProvider
+	Weight   int // 流量权重，默认1
+}
+
+// SMSRoute 按号码前缀选择服务商
+type SMSRoute struct {
+	Prefix    string // 号码前缀，如 "+86"、"+1"；为空时匹配所有号码。多条路由匹配时取最长前缀
+	Providers []WeightedSMSProvider
+}
+
+// SMSDelivery 一条短信的投递记录
+type SMSDelivery struct {
+	Phone      string
+	TemplateID string
+	Provider   string   // 实际投递成功的服务商
+	MessageID  string   // 该服务商返回的消息ID
+	Failed     []string // 投递成功之前失败的服务商
+	SentAt     time.Time
+}
+
+// CompositeConfig 组合服务商配置
+type CompositeConfig struct {
+	Routes           []SMSRoute
+	Timeout          time.Duration     // 单个服务商的发送超时，超时后切换到下一个，默认5秒
+	FailureThreshold int               // 连续失败多少次后熔断该服务商，默认3
+	Cooldown         time.Duration     // 熔断持续时间，之后进入半开状态，同一时间只放行一个试探请求，默认30秒
+	OnDelivery       func(SMSDelivery) // 投递成功后的回调，可用于记录每个验证码由哪个服务商发送
+}
+
+// CompositeProvider 组合多个服务商：按号码前缀路由，按权重分配流量，
+// 发送失败或超时时切换到下一个服务商，并对连续失败的服务商熔断
+type CompositeProvider struct {
+	config CompositeConfig
+
+	mu       sync.Mutex
+	breakers map[string]*smsBreaker
+}
+
+// 单个服务商的熔断状态
+type smsBreaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool // 半开状态下是否已有试探请求在发送
+}
+
+// ErrSMSProvidersUnavailable 表示没有匹配号码的服务商，或所有服务商都处于熔断状态
+var ErrSMSProvidersUnavailable = errors.New("no SMS provider available")
+
+// NewCompositeProvider 创建组合服务商
+func NewCompositeProvider(config CompositeConfig) (*CompositeProvider, error) {
+	if len(config.Routes) == 0 {
+		return nil, errors.New("composite SMS provider requires at least one route")
+	}
+	names := map[string]SMSProvider{}
+	for _, route := range config.Routes {
+		if len(route.Providers) == 0 {
+			return nil, fmt.Errorf("SMS route %q has no providers", route.Prefix)
+		}
+		for _, p := range route.Providers {
+			if p.Name == "" || p.Provider == nil {
+				return nil, fmt.Errorf("SMS route %q has a provider without name or implementation", route.Prefix)
+			}
+			if prev, ok := names[p.Name]; ok && prev != p.Provider {
+				return nil, fmt.Errorf("SMS provider name %q is used by different providers", p.Name)
+			}
+			if p.Weight < 0 {
+				return nil, fmt.Errorf("SMS provider %q has negative weight", p.Name)
+			}
+			names[p.Name] = p.Provider
+		}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	return &CompositeProvider{config: config, breakers: make(map[string]*smsBreaker)}, nil
+}
+
+// Send 实现 SMSProvider 接口，按权重随机排列匹配路由中的服务商并依次尝试
+func (c *CompositeProvider) Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error) {
+	route, ok := c.route(phone)
+	if !ok {
+		return "", fmt.Errorf("%w for %s", ErrSMSProvidersUnavailable, phone)
+	}
+
+	var errs []error
+	var failed []string
+	for _, p := range weightedOrder(route.Providers) {
+		allowed, probe := c.allow(p.Name)
+		if !allowed {
+			log.Warn("SMS provider %s is open-circuited, skipping", p.Name)
+			continue
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+		messageID, err := p.Provider.Send(attemptCtx, phone, templateID, params)
+		cancel()
+		if err == nil {
+			c.record(p.Name, probe, true)
+			delivery := SMSDelivery{
+				Phone:      phone,
+				TemplateID: templateID,
+				Provider:   p.Name,
+				MessageID:  messageID,
+				Failed:     failed,
+				SentAt:     time.Now(),
+			}
+			log.Info("SMS to %s delivered via %s, message ID: %s", phone, p.Name, messageID)
+			if c.config.OnDelivery != nil {
+				c.config.OnDelivery(delivery)
+			}
+			return messageID, nil
+		}
+
+		// 调用方取消或超时时不再切换，也不计为服务商的失败，只有单次发送超时才计入熔断
+		if ctx.Err() != nil {
+			c.release(p.Name, probe)
+			log.Warn("SMS to %s via %s aborted by caller: %v", phone, p.Name, ctx.Err())
+			return "", ctx.Err()
+		}
+
+		c.record(p.Name, probe, false)
+		log.Warn("SMS provider %s failed: %v", p.Name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		failed = append(failed, p.Name)
+	}
+
+	if len(errs) == 0 {
+		return "", fmt.Errorf("%w: all providers for %s are open-circuited", ErrSMSProvidersUnavailable, phone)
+	}
+	return "", errors.Join(errs...)
+}
+
+// 选择前缀最长的匹配路由
+func (c *CompositeProvider) route(phone string) (SMSRoute, bool) {
+	best, found := SMSRoute{}, false
+	for _, route := range c.config.Routes {
+		if strings.HasPrefix(phone, route.Prefix) && (!found || len(route.Prefix) > len(best.Prefix)) {
+			best, found = route, true
+		}
+	}
+	return best, found
+}
+
+// 熔断未打开时允许发送；熔断时间已过后进入半开状态，只放行一个试探请求，probe 表示本次发送是试探请求
+func (c *CompositeProvider) allow(name string) (allowed, probe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[name]
+	if !ok || b.failures < c.config.FailureThreshold {
+		return true, false
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false, false
+	}
+	b.probing = true
+	log.Info("SMS provider %s is half-open, sending a probe", name)
+	return true, true
+}
+
+// 记录发送结果，连续失败达到阈值时打开熔断；试探请求成功时关闭熔断，失败时立即重新熔断
+func (c *CompositeProvider) record(name string, probe, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[name]
+	if !ok {
+		b = &smsBreaker{}
+		c.breakers[name] = b
+	}
+	if probe {
+		b.probing = false
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= c.config.FailureThreshold {
+		b.openUntil = time.Now().Add(c.config.Cooldown)
+		log.Warn("SMS provider %s open-circuited for %v after %d consecutive failures", name, c.config.Cooldown, b.failures)
+	}
+}
+
+// 结束一次没有结果的发送，试探请求被取消时只释放半开状态，不改变失败计数
+func (c *CompositeProvider) release(name string, probe bool) {
+	if !probe {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.breakers[name]; ok {
+		b.probing = false
+	}
+}
+
+// 按权重随机排列服务商（不放回加权抽样），未设置权重时按1计算
+func weightedOrder(providers []WeightedSMSProvider) []WeightedSMSProvider {
+	rest := append([]WeightedSMSProvider(nil), providers...)
+	order := make([]WeightedSMSProvider, 0, len(rest))
+	weight := func(p WeightedSMSProvider) int {
+		if p.Weight == 0 {
+			return 1
+		}
+		return p.Weight
+	}
+	for len(rest) > 0 {
+		total := 0
+		for _, p := range rest {
+			total += weight(p)
+		}
+		n := rand.Intn(total)
+		i := 0
+		for ; n >= weight(rest[i]); i++ {
+			n -= weight(rest[i])
+		}
+		order = append(order, rest[i])
+		rest = append(rest[:i], rest[i+1:]...)
+	}
+	return order
+}
@@ -0,0 +1,197 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompositeProviderRoutingAndFailover(t *testing.T) {
+	cn, intl, backup := NewFakeSMSProvider(), NewFakeSMSProvider(), NewFakeSMSProvider()
+	var deliveries []SMSDelivery
+	provider, err := NewCompositeProvider(CompositeConfig{
+		Routes: []SMSRoute{
+			{Prefix: "+86", Providers: []WeightedSMSProvider{{Name: "cn", Provider: cn, Weight: 100}, {Name: "backup", Provider: backup, Weight: 0}}},
+			{Prefix: "", Providers: []WeightedSMSProvider{{Name: "intl", Provider: intl}}},
+		},
+		Timeout:    20 * time.Millisecond,
+		OnDelivery: func(d SMSDelivery) { deliveries = append(deliveries, d) },
+	})
+	if err != nil {
+		t.Fatalf("创建组合服务商失败: %v", err)
+	}
+
+	// 国际号码走默认路由
+	if _, err := provider.Send(context.Background(), "+14155550100", "T", nil); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if len(intl.Outbox()) != 1 || deliveries[0].Provider != "intl" {
+		t.Fatalf("国际号码应由 intl 发送: %+v", deliveries)
+	}
+
+	// 主服务商超时后切换到备用服务商
+	cn.SetDelay(time.Second)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); err != nil {
+		t.Fatalf("应切换到备用服务商: %v", err)
+	}
+	last := deliveries[len(deliveries)-1]
+	if last.Provider != "backup" || len(backup.Outbox()) != 1 {
+		t.Fatalf("应由 backup 发送: %+v", last)
+	}
+	// 按权重 cn 几乎总是先被尝试；即使 backup 排在前面，也不应记录其他失败的服务商
+	if len(last.Failed) > 1 || (len(last.Failed) == 1 && last.Failed[0] != "cn") {
+		t.Fatalf("失败记录错误: %+v", last.Failed)
+	}
+}
+
+func TestCompositeProviderCircuitBreaker(t *testing.T) {
+	primary := NewFakeSMSProvider()
+	provider, err := NewCompositeProvider(CompositeConfig{
+		Routes:           []SMSRoute{{Providers: []WeightedSMSProvider{{Name: "primary", Provider: primary}}}},
+		FailureThreshold: 2,
+		Cooldown:         time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("创建组合服务商失败: %v", err)
+	}
+
+	outage := errors.New("outage")
+	primary.FailNext(outage, outage)
+	for i := 0; i < 2; i++ {
+		if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, outage) {
+			t.Fatalf("第 %d 次发送应返回服务商错误，实际为 %v", i+1, err)
+		}
+	}
+	// 连续失败两次后熔断，不再调用该服务商
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, ErrSMSProvidersUnavailable) {
+		t.Fatalf("熔断后应返回 ErrSMSProvidersUnavailable，实际为 %v", err)
+	}
+	if len(primary.Outbox()) != 0 {
+		t.Fatalf("熔断期间不应调用服务商")
+	}
+}
+
+func TestCompositeProviderHalfOpen(t *testing.T) {
+	primary := NewFakeSMSProvider()
+	provider, err := NewCompositeProvider(CompositeConfig{
+		Routes:           []SMSRoute{{Providers: []WeightedSMSProvider{{Name: "primary", Provider: primary}}}},
+		FailureThreshold: 1,
+		Cooldown:         20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("创建组合服务商失败: %v", err)
+	}
+
+	outage := errors.New("outage")
+	primary.FailNext(outage)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, outage) {
+		t.Fatalf("首次发送应返回服务商错误，实际为 %v", err)
+	}
+
+	// 熔断时间过后并发发送，只有一个试探请求到达服务商，其余请求仍被熔断
+	time.Sleep(30 * time.Millisecond)
+	primary.SetDelay(50 * time.Millisecond)
+	const n = 10
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := provider.Send(context.Background(), "+8613800138000", "T", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, ErrSMSProvidersUnavailable) {
+			t.Fatalf("半开状态下被拒绝的请求应返回 ErrSMSProvidersUnavailable，实际为 %v", err)
+		}
+	}
+	if succeeded != 1 || len(primary.Outbox()) != 1 {
+		t.Fatalf("半开状态应只放行一个试探请求，成功 %d 次，发件箱 %d 条", succeeded, len(primary.Outbox()))
+	}
+
+	// 试探成功后关闭熔断
+	primary.SetDelay(0)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); err != nil {
+		t.Fatalf("试探成功后应恢复发送: %v", err)
+	}
+
+	// 再次熔断后试探失败，立即重新熔断
+	primary.FailNext(outage, outage)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, outage) {
+		t.Fatalf("应返回服务商错误，实际为 %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, outage) {
+		t.Fatalf("试探请求应返回服务商错误，实际为 %v", err)
+	}
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); !errors.Is(err, ErrSMSProvidersUnavailable) {
+		t.Fatalf("试探失败后应重新熔断，实际为 %v", err)
+	}
+}
+
+func TestCompositeProviderIgnoresCallerCancellation(t *testing.T) {
+	primary := NewFakeSMSProvider()
+	provider, err := NewCompositeProvider(CompositeConfig{
+		Routes:           []SMSRoute{{Providers: []WeightedSMSProvider{{Name: "primary", Provider: primary}}}},
+		FailureThreshold: 1,
+		Cooldown:         20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("创建组合服务商失败: %v", err)
+	}
+	sendCancelled := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		_, err := provider.Send(ctx, "+8613800138000", "T", nil)
+		return err
+	}
+
+	// 调用方取消的请求不计为服务商失败，不会熔断正常的服务商
+	primary.SetDelay(time.Second)
+	for i := 0; i < 3; i++ {
+		if err := sendCancelled(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("应返回调用方的超时错误，实际为 %v", err)
+		}
+	}
+	primary.SetDelay(0)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); err != nil {
+		t.Fatalf("调用方取消后服务商不应被熔断: %v", err)
+	}
+
+	// 半开状态的试探请求被取消时只释放试探名额，下一个请求仍可试探
+	primary.FailNext(errors.New("outage"))
+	provider.Send(context.Background(), "+8613800138000", "T", nil)
+	time.Sleep(30 * time.Millisecond)
+	primary.SetDelay(time.Second)
+	if err := sendCancelled(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("试探请求应返回调用方的超时错误，实际为 %v", err)
+	}
+	primary.SetDelay(0)
+	if _, err := provider.Send(context.Background(), "+8613800138000", "T", nil); err != nil {
+		t.Fatalf("取消的试探请求不应重新熔断: %v", err)
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	providers := []WeightedSMSProvider{{Name: "a", Weight: 9}, {Name: "b", Weight: 1}}
+	first := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		order := weightedOrder(providers)
+		if len(order) != 2 || order[0].Name == order[1].Name {
+			t.Fatalf("排列应包含每个服务商各一次: %+v", order)
+		}
+		first[order[0].Name]++
+	}
+	if first["a"] < 1600 || first["a"] > 1950 {
+		t.Fatalf("权重 9:1 时 a 排在第一位的比例应约为 90%%，实际为 %d/2000", first["a"])
+	}
+}
@@ -0,0 +1,155 @@
+package captcha
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SentSMS 短信发件箱中的一条记录
+type SentSMS struct {
+	MessageID  string
+	Phone      string
+	TemplateID string
+	Params     map[string]string
+	SentAt     time.Time
+}
+
+// FakeSMSProvider 不访问网络的短信服务商，把每条短信记录在发件箱中，
+// 可以模拟发送失败和延迟，用于离线测试登录等依赖短信验证码的流程
+type FakeSMSProvider struct {
+	CodeParam string // 保存验证码的模板变量名，为空时取变量名排序后第一个全数字的变量值
+
+	mu       sync.Mutex
+	outbox   []SentSMS
+	failures []error
+	delay    time.Duration
+	seq      int
+}
+
+// NewFakeSMSProvider 创建一个空发件箱的模拟服务商
+func NewFakeSMSProvider() *FakeSMSProvider {
+	return &FakeSMSProvider{}
+}
+
+// Send 实现 SMSProvider 接口
+//
+// 设置了延迟时先等待，期间 ctx 取消则返回 ctx.Err()；有待返回的错误时按顺序返回一个错误且不记录短信。
+func (f *FakeSMSProvider) Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error) {
+	f.mu.Lock()
+	delay := f.delay
+	f.mu.Unlock()
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return "", err
+	}
+
+	f.seq++
+	msg := SentSMS{
+		MessageID:  "fake-" + strconv.Itoa(f.seq),
+		Phone:      phone,
+		TemplateID: templateID,
+		Params:     make(map[string]string, len(params)),
+		SentAt:     time.Now(),
+	}
+	for k, v := range params {
+		msg.Params[k] = v
+	}
+	f.outbox = append(f.outbox, msg)
+	return msg.MessageID, nil
+}
+
+// FailNext 让接下来的发送依次返回给定的错误
+func (f *FakeSMSProvider) FailNext(errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, errs...)
+}
+
+// SetDelay 设置每次发送前的延迟，用于测试超时
+func (f *FakeSMSProvider) SetDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = delay
+}
+
+// Outbox 返回发件箱中的所有短信，按发送顺序排列
+func (f *FakeSMSProvider) Outbox() []SentSMS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SentSMS(nil), f.outbox...)
+}
+
+// Reset 清空发件箱、待返回的错误和延迟
+func (f *FakeSMSProvider) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outbox, f.failures, f.delay = nil, nil, 0
+}
+
+// LastSentTo 返回最近一条发给 phone 的短信，phone 可以是任意能被 NormalizePhoneNumber 解析的格式
+func (f *FakeSMSProvider) LastSentTo(phone string) (SentSMS, bool) {
+	normalized, err := NormalizePhoneNumber(phone)
+	if err != nil {
+		normalized = phone
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.outbox) - 1; i >= 0; i-- {
+		if f.outbox[i].Phone == normalized || f.outbox[i].Phone == phone {
+			return f.outbox[i], true
+		}
+	}
+	return SentSMS{}, false
+}
+
+// LastCodeSentTo 返回最近一条发给 phone 的短信中的验证码
+func (f *FakeSMSProvider) LastCodeSentTo(phone string) (string, bool) {
+	msg, ok := f.LastSentTo(phone)
+	if !ok {
+		return "", false
+	}
+	if f.CodeParam != "" {
+		code, ok := msg.Params[f.CodeParam]
+		return code, ok
+	}
+
+	keys := make([]string, 0, len(msg.Params))
+	for k := range msg.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if isDigits(msg.Params[k]) {
+			return msg.Params[k], true
+		}
+	}
+	return "", false
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeSMSProviderLoginFlow(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+
+	if _, err := SendCaptchaToPhone("13800138000", "SMS_LOGIN", `{"code":"{{.code1}}","product":"demo"}`, 6); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	code, ok := fake.LastCodeSentTo("13800138000")
+	if !ok || len(code) != 6 {
+		t.Fatalf("发件箱中应有发给该号码的6位验证码，实际为 %q", code)
+	}
+	if msg, _ := fake.LastSentTo("13800138000"); msg.TemplateID != "SMS_LOGIN" || msg.MessageID == "" {
+		t.Fatalf("发件箱记录错误: %+v", msg)
+	}
+	// 短信验证码不能当作图片验证码使用
+	if Verify("+8613800138000", code) {
+		t.Fatalf("短信验证码不应通过图片验证码的 Verify")
+	}
+	if !VerifyCode("13800138000", code) {
+		t.Fatalf("发件箱中的验证码应能通过验证")
+	}
+}
+
+func TestFakeSMSProviderScriptedFailure(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+
+	outage := errors.New("vendor outage")
+	fake.FailNext(outage)
+	if _, err := SendCaptchaToPhone("13900139000", "SMS_LOGIN", `{"code":"{{.code1}}"}`, 6); !errors.Is(err, outage) {
+		t.Fatalf("应返回预设的错误，实际为 %v", err)
+	}
+	if len(fake.Outbox()) != 0 || getCaptcha("13900139000") != nil {
+		t.Fatalf("发送失败时不应记录短信或存储验证码")
+	}
+
+	// 预设的错误只生效一次
+	if _, err := SendCaptchaToPhone("13900139000", "SMS_LOGIN", `{"code":"{{.code1}}"}`, 6); err != nil {
+		t.Fatalf("第二次发送应成功: %v", err)
+	}
+}
+
+func TestFakeSMSProviderDelay(t *testing.T) {
+	fake := NewFakeSMSProvider()
+	fake.SetDelay(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fake.Send(ctx, "13800138000", "SMS_1", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("超时应返回 DeadlineExceeded，实际为 %v", err)
+	}
+}
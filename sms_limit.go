@@ -0,0 +1,258 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// CounterStore 限流计数器存储，多副本部署时可用 Redis 等共享存储实现
+//
+// Incr 将 key 的计数加一，返回加一后的计数和当前窗口的剩余时间；
+// key 不存在或窗口已过期时新建一个长度为 window 的窗口（如 Redis 的 INCR 加 PEXPIRE NX）。
+// Decr 撤销一次 Incr，发送被其他规则拒绝或发送失败时调用；key 不存在或窗口已过期时不做任何操作。
+type CounterStore interface {
+	Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+	Decr(ctx context.Context, key string) error
+}
+
+// SendLimitConfig 验证码的发送限制，按接收方（如邮箱地址）和客户端IP计数，值为0的规则不生效
+type SendLimitConfig struct {
+	Cooldown        time.Duration // 同一接收方两次发送的最小间隔，如60秒
+	RecipientHourly int           // 每个接收方每小时最多发送次数
+	RecipientDaily  int           // 每个接收方每天最多发送次数
+	IPHourly        int           // 每个客户端IP每小时最多发送次数，IP 通过 WithClientIP 传入
+	IPDaily         int           // 每个客户端IP每天最多发送次数
+	Store           CounterStore  // 计数器存储，默认为进程内存储
+}
+
+// SMSLimitConfig 手机验证码的发送限制，值为0的规则不生效
+type SMSLimitConfig struct {
+	Cooldown    time.Duration // 同一手机号两次发送的最小间隔，如60秒
+	PhoneHourly int           // 每个手机号每小时最多发送次数
+	PhoneDaily  int           // 每个手机号每天最多发送次数
+	IPHourly    int           // 每个客户端IP每小时最多发送次数，IP 通过 WithClientIP 传入
+	IPDaily     int           // 每个客户端IP每天最多发送次数
+	Store       CounterStore  // 计数器存储，默认为进程内存储
+}
+
+// ErrRateLimited 表示发送次数超出限制，具体信息见 *RateLimitError
+var ErrRateLimited = errors.New("captcha send rate limited")
+
+// RateLimitError 发送被限制时返回的错误
+type RateLimitError struct {
+	Rule       string        // 触发的规则：cooldown、phone_hourly、phone_daily、email_hourly、email_daily、ip_hourly、ip_daily
+	RetryAfter time.Duration // 需要等待多久才能再次发送
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("send rate limited by %s, retry after %v", e.Rule, e.RetryAfter.Round(time.Second))
+}
+
+// Is 使 errors.Is(err, ErrRateLimited) 成立
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// 转换为通用的发送限制，接收方为手机号
+func (c SMSLimitConfig) sendLimit() SendLimitConfig {
+	return SendLimitConfig{
+		Cooldown:        c.Cooldown,
+		RecipientHourly: c.PhoneHourly,
+		RecipientDaily:  c.PhoneDaily,
+		IPHourly:        c.IPHourly,
+		IPDaily:         c.IPDaily,
+		Store:           c.Store,
+	}
+}
+
+var (
+	smsLimit     SMSLimitConfig
+	smsLimitLock sync.RWMutex
+)
+
+// SetSMSLimitConfig 设置手机验证码的发送限制
+func SetSMSLimitConfig(config SMSLimitConfig) {
+	if config.Store == nil {
+		config.Store = NewMemoryCounterStore()
+	}
+
+	smsLimitLock.Lock()
+	defer smsLimitLock.Unlock()
+	smsLimit = config
+	log.Info("Set SMS limit config: cooldown=%v, phone=%d/h %d/d, ip=%d/h %d/d",
+		config.Cooldown, config.PhoneHourly, config.PhoneDaily, config.IPHourly, config.IPDaily)
+}
+
+type clientIPKey struct{}
+
+// WithClientIP 在 ctx 中记录请求方的IP，用于按IP限制发送次数
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext 返回 WithClientIP 记录的IP
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok && ip != ""
+}
+
+// 一条限流规则：window 内 key 最多计数 limit 次
+type limitRule struct {
+	name   string
+	key    string
+	limit  int
+	window time.Duration
+}
+
+// 一次发送已计入的计数，发送被拒绝或失败时用 rollback 撤销
+type limitCharge struct {
+	entries []limitChargeEntry
+}
+
+type limitChargeEntry struct {
+	store CounterStore
+	key   string
+}
+
+// 撤销所有已计入的计数，ctx 已取消时仍然执行
+func (c *limitCharge) rollback(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		e := c.entries[i]
+		if err := e.store.Decr(ctx, e.key); err != nil {
+			log.Warn("Failed to roll back rate limit counter %s: %v", e.key, err)
+		}
+	}
+	c.entries = nil
+}
+
+// 发送被取消或超时时服务商可能仍在发送，结果未知，不能撤销已计入的计数
+func deliveryUnknown(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// 检查并计入一次发送，任一规则超限时撤销已计入的计数并返回 *RateLimitError；
+// 服务商明确拒绝发送时调用方需调用返回值的 rollback，失败的发送不占用冷却时间和配额，
+// 结果未知（见 deliveryUnknown）时保留计数
+//
+// 先检查全局限制，再检查用途单独的限制（计数键带用途前缀，各用途分别计数）。
+func checkSMSLimit(ctx context.Context, phone string, purpose OTPPurpose, purposeLimit *SMSLimitConfig) (*limitCharge, error) {
+	smsLimitLock.RLock()
+	config := smsLimit
+	smsLimitLock.RUnlock()
+
+	charge := &limitCharge{}
+	if err := applySendLimit(ctx, config.sendLimit(), "sms:", "phone", phone, charge); err != nil {
+		charge.rollback(ctx)
+		return nil, err
+	}
+	if purposeLimit != nil {
+		if err := applySendLimit(ctx, purposeLimit.sendLimit(), "sms:"+string(purpose)+":", "phone", phone, charge); err != nil {
+			charge.rollback(ctx)
+			return nil, err
+		}
+	}
+	return charge, nil
+}
+
+// 按一组限制检查并计入一次发送，计数键以 prefix 开头；kind 为接收方类型（phone 或 email），
+// 用于计数键和规则名，Recipient 开头的规则按接收方 target 计数
+//
+// 每条规则先计数再比较，计入的计数记录在 charge 中；超限时由调用方撤销 charge，
+// 因此只有最终放行的发送会占用各规则的配额，被接收方规则拒绝的请求也不会消耗IP的配额。
+func applySendLimit(ctx context.Context, config SendLimitConfig, prefix, kind, target string, charge *limitCharge) error {
+	if config.Store == nil {
+		return nil
+	}
+
+	var rules []limitRule
+	if ip, ok := ClientIPFromContext(ctx); ok {
+		rules = append(rules,
+			limitRule{"ip_hourly", prefix + "ip:h:" + ip, config.IPHourly, time.Hour},
+			limitRule{"ip_daily", prefix + "ip:d:" + ip, config.IPDaily, 24 * time.Hour},
+		)
+	}
+	rules = append(rules,
+		limitRule{"cooldown", prefix + kind + ":cd:" + target, 1, config.Cooldown},
+		limitRule{kind + "_hourly", prefix + kind + ":h:" + target, config.RecipientHourly, time.Hour},
+		limitRule{kind + "_daily", prefix + kind + ":d:" + target, config.RecipientDaily, 24 * time.Hour},
+	)
+
+	for _, rule := range rules {
+		if rule.limit <= 0 || rule.window <= 0 {
+			continue
+		}
+		count, ttl, err := config.Store.Incr(ctx, rule.key, rule.window)
+		if err != nil {
+			return fmt.Errorf("check SMS rate limit: %w", err)
+		}
+		charge.entries = append(charge.entries, limitChargeEntry{config.Store, rule.key})
+		if count > int64(rule.limit) {
+			log.Warn("Send to %s rate limited by %s, retry after %v", target, rule.name, ttl)
+			return &RateLimitError{Rule: rule.name, RetryAfter: ttl}
+		}
+	}
+	return nil
+}
+
+// MemoryCounterStore 进程内的计数器存储，只适用于单实例部署
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+	sweepAt  time.Time
+}
+
+type memoryCounter struct {
+	count    int64
+	expireAt time.Time
+}
+
+// NewMemoryCounterStore 创建进程内计数器存储
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{counters: make(map[string]*memoryCounter)}
+}
+
+// Incr 实现 CounterStore 接口
+func (s *MemoryCounterStore) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 每分钟最多清理一次过期的计数器
+	if now.After(s.sweepAt) {
+		for k, c := range s.counters {
+			if !now.Before(c.expireAt) {
+				delete(s.counters, k)
+			}
+		}
+		s.sweepAt = now.Add(time.Minute)
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expireAt) {
+		c = &memoryCounter{expireAt: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, c.expireAt.Sub(now), nil
+}
+
+// Decr 实现 CounterStore 接口
+func (s *MemoryCounterStore) Decr(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !time.Now().Before(c.expireAt) {
+		return nil
+	}
+	if c.count--; c.count <= 0 {
+		delete(s.counters, key)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 设置发送限制，测试结束时关闭
+func setSMSLimit(t *testing.T, config SMSLimitConfig) {
+	SetSMSLimitConfig(config)
+	t.Cleanup(func() {
+		smsLimitLock.Lock()
+		smsLimit = SMSLimitConfig{}
+		smsLimitLock.Unlock()
+	})
+}
+
+func TestSMSCooldownAndPhoneQuota(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	setSMSLimit(t, SMSLimitConfig{Cooldown: 50 * time.Millisecond, PhoneHourly: 2})
+
+	tpl := `{"code":"{{.code1}}"}`
+	if _, err := SendCaptchaToPhone("13800138001", "T", tpl, 6); err != nil {
+		t.Fatalf("第一次发送失败: %v", err)
+	}
+
+	_, err := SendCaptchaToPhone("13800138001", "T", tpl, 6)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrRateLimited) || limitErr.Rule != "cooldown" {
+		t.Fatalf("冷却期内应返回 cooldown 限制，实际为 %v", err)
+	}
+	if limitErr.RetryAfter <= 0 || limitErr.RetryAfter > 50*time.Millisecond {
+		t.Fatalf("RetryAfter 错误: %v", limitErr.RetryAfter)
+	}
+	// 其他手机号不受影响
+	if _, err := SendCaptchaToPhone("13800138002", "T", tpl, 6); err != nil {
+		t.Fatalf("其他手机号发送失败: %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := SendCaptchaToPhone("13800138001", "T", tpl, 6); err != nil {
+		t.Fatalf("冷却结束后发送失败: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	_, err = SendCaptchaToPhone("13800138001", "T", tpl, 6)
+	if !errors.As(err, &limitErr) || limitErr.Rule != "phone_hourly" || limitErr.RetryAfter < 59*time.Minute {
+		t.Fatalf("超出每小时次数应返回 phone_hourly 限制，实际为 %v", err)
+	}
+	if len(fake.Outbox()) != 3 {
+		t.Fatalf("被限制的请求不应发送短信，发件箱有 %d 条", len(fake.Outbox()))
+	}
+}
+
+func TestSMSClientIPQuota(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	setSMSLimit(t, SMSLimitConfig{IPDaily: 2, PhoneDaily: 5})
+
+	ctx := WithClientIP(context.Background(), "203.0.113.7")
+	tpl := `{"code":"{{.code1}}"}`
+	for _, phone := range []string{"13800138003", "13800138004"} {
+		if _, err := SendCaptchaToPhoneContext(ctx, phone, "T", tpl, 6); err != nil {
+			t.Fatalf("发送失败: %v", err)
+		}
+	}
+	_, err := SendCaptchaToPhoneContext(ctx, "13800138005", "T", tpl, 6)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != "ip_daily" {
+		t.Fatalf("超出IP每天次数应返回 ip_daily 限制，实际为 %v", err)
+	}
+	// 被限制的IP不消耗目标手机号的配额
+	count, _, _ := smsLimit.Store.Incr(context.Background(), "sms:phone:d:13800138005", 24*time.Hour)
+	if count != 1 {
+		t.Fatalf("被IP限制的请求不应计入手机号配额，计数为 %d", count)
+	}
+	// 没有IP时只按手机号限制
+	if _, err := SendCaptchaToPhone("13800138005", "T", tpl, 6); err != nil {
+		t.Fatalf("没有IP时发送失败: %v", err)
+	}
+}
+
+func TestSMSLimitCountsOnlySentMessages(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	setSMSLimit(t, SMSLimitConfig{Cooldown: time.Minute, IPHourly: 3})
+
+	ctx := WithClientIP(context.Background(), "203.0.113.8")
+	tpl := `{"code":"{{.code1}}"}`
+	if _, err := SendCaptchaToPhoneContext(ctx, "13800138006", "T", tpl, 6); err != nil {
+		t.Fatalf("第一次发送失败: %v", err)
+	}
+	// 被冷却时间拒绝的请求不消耗IP的配额
+	for i := 0; i < 3; i++ {
+		if _, err := SendCaptchaToPhoneContext(ctx, "13800138006", "T", tpl, 6); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("冷却期内应被限制，实际为 %v", err)
+		}
+	}
+	for _, phone := range []string{"13800138007", "13800138008"} {
+		if _, err := SendCaptchaToPhoneContext(ctx, phone, "T", tpl, 6); err != nil {
+			t.Fatalf("IP配额未用完时发送失败: %v", err)
+		}
+	}
+	_, err := SendCaptchaToPhoneContext(ctx, "13800138009", "T", tpl, 6)
+	var limitErr *RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != "ip_hourly" {
+		t.Fatalf("超出IP每小时次数应返回 ip_hourly 限制，实际为 %v", err)
+	}
+
+	// 服务商发送失败时不开始冷却，可以立即重试
+	outage := errors.New("outage")
+	fake.FailNext(outage)
+	if _, err := SendCaptchaToPhone("13800138010", "T", tpl, 6); !errors.Is(err, outage) {
+		t.Fatalf("应返回服务商错误，实际为 %v", err)
+	}
+	if _, err := SendCaptchaToPhone("13800138010", "T", tpl, 6); err != nil {
+		t.Fatalf("发送失败后重试不应被冷却时间限制: %v", err)
+	}
+}
+
+func TestSMSLimitKeptWhenDeliveryUnknown(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	setSMSLimit(t, SMSLimitConfig{Cooldown: time.Minute})
+
+	// 调用方中断请求时短信可能仍会送达，冷却时间照常生效
+	fake.SetDelay(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	tpl := SMSTemplate{TemplateCode: "T"}
+	if _, err := SendCaptchaToPhoneTemplate(ctx, "13800138011", tpl, 6); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回超时错误，实际为 %v", err)
+	}
+	fake.SetDelay(0)
+	if _, err := SendCaptchaToPhoneTemplate(context.Background(), "13800138011", tpl, 6); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("中断的发送仍应计入冷却时间，实际为 %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMSTemplate 结构化的短信模板，验证码和其他变量作为模板变量传给服务商，由服务商负责编码（如阿里云编码为 JSON），
+// 变量值中的引号等字符不会破坏请求格式
+type SMSTemplate struct {
+	TemplateCode string            // 服务商的模板编号
+	CodeParam    string            // 验证码的变量名，默认 "code"
+	ExpiryParam  string            // 有效期分钟数的变量名，为空时不传
+	Params       map[string]string // 其他固定变量，如 {"product": "示例应用"}
+}
+
+// 默认的验证码变量名
+const defaultCodeParam = "code"
+
+// Validate 检查模板配置，变量名不能为空或重复
+func (t SMSTemplate) Validate() error {
+	if t.TemplateCode == "" {
+		return errors.New("SMS template requires TemplateCode")
+	}
+	codeParam := t.codeParam()
+	if t.ExpiryParam == codeParam {
+		return fmt.Errorf("SMS template %s: ExpiryParam %q conflicts with CodeParam", t.TemplateCode, t.ExpiryParam)
+	}
+	for name := range t.Params {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("SMS template %s: empty parameter name", t.TemplateCode)
+		}
+		if name == codeParam || name == t.ExpiryParam {
+			return fmt.Errorf("SMS template %s: parameter %q conflicts with CodeParam or ExpiryParam", t.TemplateCode, name)
+		}
+	}
+	return nil
+}
+
+func (t SMSTemplate) codeParam() string {
+	if t.CodeParam == "" {
+		return defaultCodeParam
+	}
+	return t.CodeParam
+}
+
+// 生成发给服务商的模板变量
+func (t SMSTemplate) params(code string, ttl time.Duration) map[string]string {
+	params := make(map[string]string, len(t.Params)+2)
+	for k, v := range t.Params {
+		params[k] = v
+	}
+	params[t.codeParam()] = code
+	if t.ExpiryParam != "" {
+		params[t.ExpiryParam] = strconv.Itoa(int((ttl + time.Minute - 1) / time.Minute))
+	}
+	return params
+}
+
+type localeKey struct{}
+
+// WithLocale 在 ctx 中记录用户的语言（BCP 47 标签，如 "zh-CN"、"en-US"），用于选择本地化的短信模板
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext 返回 WithLocale 记录的语言
+func LocaleFromContext(ctx context.Context) (string, bool) {
+	locale, ok := ctx.Value(localeKey{}).(string)
+	return locale, ok && locale != ""
+}
+
+// 按语言选择模板：先精确匹配（不区分大小写，"_" 视为 "-"），再匹配主语言（如 "en-US" 匹配 "en"），都没有时使用默认模板
+func selectSMSTemplate(config PurposeConfig, locale string) SMSTemplate {
+	if locale == "" || len(config.Locales) == 0 {
+		return config.Template
+	}
+	want := normalizeLocale(locale)
+	base, _, _ := strings.Cut(want, "-")
+	var baseMatch *SMSTemplate
+	for tag, tpl := range config.Locales {
+		switch normalizeLocale(tag) {
+		case want:
+			return tpl
+		case base:
+			tpl := tpl
+			baseMatch = &tpl
+		}
+	}
+	if baseMatch != nil {
+		return *baseMatch
+	}
+	return config.Template
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
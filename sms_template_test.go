@@ -0,0 +1,94 @@
+package captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSMSTemplateValidate(t *testing.T) {
+	for i, tpl := range []SMSTemplate{
+		{},
+		{TemplateCode: "T", ExpiryParam: "code"},
+		{TemplateCode: "T", Params: map[string]string{"code": "x"}},
+		{TemplateCode: "T", ExpiryParam: "ttl", Params: map[string]string{"ttl": "x"}},
+		{TemplateCode: "T", Params: map[string]string{" ": "x"}},
+	} {
+		if err := tpl.Validate(); err == nil {
+			t.Errorf("第 %d 个模板应校验失败", i)
+		}
+	}
+	if err := SetPurposeConfig(PurposeLogin, PurposeConfig{
+		Template: SMSTemplate{TemplateCode: "SMS_LOGIN"},
+		Locales:  map[string]SMSTemplate{"en": {}},
+	}); err == nil {
+		t.Fatalf("本地化模板无效时 SetPurposeConfig 应返回错误")
+	}
+}
+
+func TestSendCaptchaToPhoneTemplate(t *testing.T) {
+	resetSMSProviders(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+
+	code, err := SendCaptchaToPhoneTemplate(context.Background(), "13800138020", SMSTemplate{
+		TemplateCode: "SMS_1",
+		CodeParam:    "otp",
+		ExpiryParam:  "minutes",
+		Params:       map[string]string{"product": `示例"应用`},
+	}, 6)
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	msg, _ := fake.LastSentTo("13800138020")
+	if msg.TemplateID != "SMS_1" || msg.Params["otp"] != code || msg.Params["minutes"] != "1" || msg.Params["product"] != `示例"应用` {
+		t.Fatalf("模板变量错误: %+v", msg)
+	}
+	if !VerifyCode("13800138020", code) {
+		t.Fatalf("验证码应能通过 VerifyCode 验证")
+	}
+}
+
+func TestSendPhoneOTPLocale(t *testing.T) {
+	resetSMSProviders(t)
+	resetPurposeConfigs(t)
+	fake := NewFakeSMSProvider()
+	RegisterSMSProvider("fake", fake)
+	err := SetPurposeConfig(PurposeLogin, PurposeConfig{
+		Template: SMSTemplate{TemplateCode: "SMS_LOGIN_ZH", ExpiryParam: "minutes"},
+		Locales: map[string]SMSTemplate{
+			"en":    {TemplateCode: "SMS_LOGIN_EN", ExpiryParam: "minutes"},
+			"zh-TW": {TemplateCode: "SMS_LOGIN_TW"},
+		},
+		TTL: 5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("设置用途配置失败: %v", err)
+	}
+
+	tests := []struct {
+		locale, template string
+	}{
+		{"", "SMS_LOGIN_ZH"},
+		{"en-US", "SMS_LOGIN_EN"},
+		{"zh_TW", "SMS_LOGIN_TW"},
+		{"fr", "SMS_LOGIN_ZH"},
+	}
+	for i, tt := range tests {
+		ctx := context.Background()
+		if tt.locale != "" {
+			ctx = WithLocale(ctx, tt.locale)
+		}
+		phone := "1380013803" + string(rune('0'+i))
+		if _, err := SendPhoneOTP(ctx, PurposeLogin, phone, 6); err != nil {
+			t.Fatalf("发送失败: %v", err)
+		}
+		msg, _ := fake.LastSentTo(phone)
+		if msg.TemplateID != tt.template {
+			t.Errorf("语言 %q 应使用模板 %s，实际为 %s", tt.locale, tt.template, msg.TemplateID)
+		}
+		if tt.template == "SMS_LOGIN_EN" && msg.Params["minutes"] != "5" {
+			t.Errorf("有效期变量应为5分钟，实际为 %q", msg.Params["minutes"])
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubSMSProvider struct {
+	phone, templateID string
+	params            map[string]string
+}
+
+func (p *stubSMSProvider) Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error) {
+	p.phone, p.templateID, p.params = phone, templateID, params
+	return "msg-1", nil
+}
+
+// 清空服务商注册表，测试结束时恢复
+func resetSMSProviders(t *testing.T) {
+	smsLock.Lock()
+	saved, savedDefault := smsProviders, defaultSMSProvider
+	smsProviders, defaultSMSProvider = map[string]SMSProvider{}, ""
+	smsLock.Unlock()
+	t.Cleanup(func() {
+		smsLock.Lock()
+		smsProviders, defaultSMSProvider = saved, savedDefault
+		smsLock.Unlock()
+	})
+}
+
+func TestSendCaptchaToPhoneUsesProvider(t *testing.T) {
+	resetSMSProviders(t)
+
+	if _, err := SendCaptchaToPhone("13800138000", "SMS_1", `{"code":"{{.code1}}"}`, 6); !errors.Is(err, ErrNoSMSProvider) {
+		t.Fatalf("没有服务商时应返回 ErrNoSMSProvider，实际为 %v", err)
+	}
+
+	stub := &stubSMSProvider{}
+	if err := RegisterSMSProvider("stub", stub); err != nil {
+		t.Fatalf("注册服务商失败: %v", err)
+	}
+	code, err := SendCaptchaToPhone("13800138000", "SMS_1", `{"code":"{{.code1}}","product":"demo"}`, 6)
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if stub.phone != "+8613800138000" || stub.templateID != "SMS_1" || stub.params["code"] != code || stub.params["product"] != "demo" {
+		t.Fatalf("服务商收到的参数错误: %+v", stub)
+	}
+	if !VerifyCode("+86 138-0013-8000", code) {
+		t.Fatalf("发送的验证码应能通过验证")
+	}
+}
+
+func TestSendCaptchaToPhoneRejectsInvalidTemplate(t *testing.T) {
+	resetSMSProviders(t)
+	RegisterSMSProvider("stub", &stubSMSProvider{})
+
+	if _, err := SendCaptchaToPhone("13800138000", "SMS_1", `code={{.code1}}`, 6); err == nil {
+		t.Fatalf("模板渲染结果不是 JSON 时应返回错误")
+	}
+}
+
+func TestSetDefaultSMSProvider(t *testing.T) {
+	resetSMSProviders(t)
+	a, b := &stubSMSProvider{}, &stubSMSProvider{}
+	RegisterSMSProvider("a", a)
+	RegisterSMSProvider("b", b)
+
+	if err := SetDefaultSMSProvider("missing"); err == nil {
+		t.Fatalf("未注册的服务商应返回错误")
+	}
+	if err := SetDefaultSMSProvider("b"); err != nil {
+		t.Fatalf("设置默认服务商失败: %v", err)
+	}
+	if _, err := SendCaptchaToPhone("13800138000", "SMS_1", `{"code":"{{.code1}}"}`, 4); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if a.phone != "" || b.phone == "" {
+		t.Fatalf("应通过默认服务商 b 发送")
+	}
+}
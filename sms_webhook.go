@@ -0,0 +1,191 @@
+package captcha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookConfig 通用 HTTP 短信服务商配置，适用于提供简单 HTTP/JSON 接口的服务商
+//
+// URL 和 Body 是 text/template 模板，可使用 .Phone、.TemplateID 和 .Params（模板变量）；
+// 模板中可用 json 函数输出 JSON 字符串（含引号），用 urlquery 函数对查询参数转义。
+type WebhookConfig struct {
+	URL         string            // 请求地址模板
+	Method      string            // 请求方法，默认 POST
+	Headers     map[string]string // 请求头，如鉴权令牌
+	Body        string            // 请求体模板，为空时不发送请求体
+	ContentType string            // 请求体类型，默认 application/json
+	Timeout     time.Duration     // 单次请求超时，默认10秒
+	Client      *http.Client      // 自定义 HTTP 客户端，默认 http.DefaultClient
+
+	// 响应为 2xx 时，如果设置了 SuccessField，还要求 JSON 响应中该字段（以点号分隔的路径，如 "result.code"）
+	// 的值等于 SuccessValue 才算发送成功
+	SuccessField string
+	SuccessValue string
+	// 从 JSON 响应中提取消息ID的字段路径，如 "data.messageId"，为空时消息ID为空
+	MessageIDField string
+}
+
+// WebhookProvider 通用 HTTP 短信服务商，新增服务商只需配置而不用写代码
+type WebhookProvider struct {
+	config WebhookConfig
+	url    *template.Template
+	body   *template.Template
+}
+
+// 模板中可用的数据
+type webhookData struct {
+	Phone      string
+	TemplateID string
+	Params     map[string]string
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewWebhookProvider 创建 HTTP 短信服务商，模板在此时解析，配置错误会立即返回
+func NewWebhookProvider(config WebhookConfig) (*WebhookProvider, error) {
+	if config.URL == "" {
+		return nil, errors.New("webhook SMS provider requires URL")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/json"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	urlTpl, err := template.New("url").Funcs(webhookFuncs).Option("missingkey=error").Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook URL template: %w", err)
+	}
+	bodyTpl, err := template.New("body").Funcs(webhookFuncs).Option("missingkey=error").Parse(config.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook body template: %w", err)
+	}
+	return &WebhookProvider{config: config, url: urlTpl, body: bodyTpl}, nil
+}
+
+// Send 实现 SMSProvider 接口
+func (p *WebhookProvider) Send(ctx context.Context, phone, templateID string, params map[string]string) (string, error) {
+	data := webhookData{Phone: phone, TemplateID: templateID, Params: params}
+	var url, body bytes.Buffer
+	if err := p.url.Execute(&url, data); err != nil {
+		return "", fmt.Errorf("render webhook URL: %w", err)
+	}
+	if err := p.body.Execute(&body, data); err != nil {
+		return "", fmt.Errorf("render webhook body: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+	var reqBody io.Reader
+	if body.Len() > 0 {
+		reqBody = &body
+	}
+	req, err := http.NewRequestWithContext(ctx, p.config.Method, url.String(), reqBody)
+	if err != nil {
+		return "", err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", p.config.ContentType)
+	}
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.config.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("webhook SMS provider returned %s: %s", resp.Status, truncate(string(respBody), 200))
+	}
+	if p.config.SuccessField == "" && p.config.MessageIDField == "" {
+		return "", nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(respBody, &doc); err != nil {
+		return "", fmt.Errorf("decode webhook response: %w", err)
+	}
+	if p.config.SuccessField != "" {
+		if got, _ := lookupJSONPath(doc, p.config.SuccessField); got != p.config.SuccessValue {
+			return "", fmt.Errorf("webhook SMS provider failed: %s = %q: %s", p.config.SuccessField, got, truncate(string(respBody), 200))
+		}
+	}
+	messageID, _ := lookupJSONPath(doc, p.config.MessageIDField)
+	return messageID, nil
+}
+
+// 按点号分隔的路径查找 JSON 值并转换为字符串，数组元素用下标访问，如 "data.list.0.id"
+func lookupJSONPath(doc interface{}, path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "", true
+	default:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	}
+}
+
+// 截断过长的字符串，用于错误信息
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,83 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookProvider(t *testing.T) {
+	var got struct {
+		Mobile string `json:"mobile"`
+		Tpl    string `json:"tpl"`
+		Code   string `json:"code"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" ||
+			r.URL.Query().Get("sign") != "Demo Inc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		if got.Code == "000000" {
+			io.WriteString(w, `{"result":{"code":"E_BLOCKED"}}`)
+			return
+		}
+		io.WriteString(w, `{"result":{"code":"OK"},"data":{"ids":["msg-42"]}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewWebhookProvider(WebhookConfig{
+		URL:            server.URL + `/send?sign={{urlquery "Demo Inc"}}`,
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		Body:           `{"mobile":{{json .Phone}},"tpl":{{json .TemplateID}},"code":{{json .Params.code}}}`,
+		SuccessField:   "result.code",
+		SuccessValue:   "OK",
+		MessageIDField: "data.ids.0",
+	})
+	if err != nil {
+		t.Fatalf("创建服务商失败: %v", err)
+	}
+
+	id, err := provider.Send(context.Background(), "+8613800138000", "LOGIN", map[string]string{"code": `12"34`})
+	if err != nil || id != "msg-42" {
+		t.Fatalf("发送结果错误: %q, %v", id, err)
+	}
+	if got.Mobile != "+8613800138000" || got.Tpl != "LOGIN" || got.Code != `12"34` {
+		t.Fatalf("请求体错误: %+v", got)
+	}
+
+	// 响应中的状态字段不匹配时视为失败
+	if _, err := provider.Send(context.Background(), "+8613800138000", "LOGIN", map[string]string{"code": "000000"}); err == nil {
+		t.Fatalf("状态字段不为 OK 时应返回错误")
+	}
+	// 模板引用了不存在的变量
+	if _, err := provider.Send(context.Background(), "+8613800138000", "LOGIN", map[string]string{}); err == nil {
+		t.Fatalf("缺少模板变量时应返回错误")
+	}
+}
+
+func TestWebhookProviderHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	provider, err := NewWebhookProvider(WebhookConfig{URL: server.URL, Method: http.MethodGet})
+	if err != nil {
+		t.Fatalf("创建服务商失败: %v", err)
+	}
+	if _, err := provider.Send(context.Background(), "13800138000", "LOGIN", nil); err == nil {
+		t.Fatalf("非 2xx 响应应返回错误")
+	}
+}
+
+func TestWebhookProviderInvalidTemplate(t *testing.T) {
+	if _, err := NewWebhookProvider(WebhookConfig{URL: "http://example.invalid", Body: `{{.Phone`}); err == nil {
+		t.Fatalf("模板语法错误应在创建时返回")
+	}
+}
@@ -8,10 +8,22 @@ import (
 	log "github.com/yowaimono/captcha/internal/log"
 )
 
+// CaptchaType 验证码类型，决定 Verify 如何比对用户输入
+type CaptchaType string
+
+const (
+	TypeText CaptchaType = "text" // 字符验证码
+	TypeGrid CaptchaType = "grid" // 九宫格选图验证码
+	TypePoW  CaptchaType = "pow"  // 工作量证明（无感）验证码
+	TypeOTP  CaptchaType = "otp"  // 发送到手机或邮箱的验证码，只能用 VerifyCode、VerifyPhoneOTP、VerifyEmailOTP 验证
+)
+
 // CaptchaInfo 存储验证码信息
 type CaptchaInfo struct {
-	Code      string
-	ExpiresAt time.Time
+	Type       CaptchaType
+	Code       string
+	Difficulty int // 工作量证明难度（前导零位数），仅 TypePoW 使用
+	ExpiresAt  time.Time
 }
 
 var (
@@ -21,14 +33,25 @@ var (
 
 // 存储验证码信息
 func storeCaptcha(captchaID, code string) {
+	storeCaptchaInfo(captchaID, &CaptchaInfo{
+		Type: TypeText,
+		Code: code,
+	})
+}
+
+// 验证码的默认有效期
+const defaultCaptchaTTL = 60 * time.Second
+
+// 存储完整的验证码信息，未设置过期时间时默认60秒后过期
+func storeCaptchaInfo(captchaID string, info *CaptchaInfo) {
 	captchaLock.Lock()
 	defer captchaLock.Unlock()
 
-	captchaMap[captchaID] = &CaptchaInfo{
-		Code:      code,
-		ExpiresAt: time.Now().Add(60 * time.Second),
+	if info.ExpiresAt.IsZero() {
+		info.ExpiresAt = time.Now().Add(defaultCaptchaTTL)
 	}
-	log.Info("Stored captcha with ID: %s, code: %s", captchaID, code)
+	captchaMap[captchaID] = info
+	log.Info("Stored captcha with ID: %s, type: %s, code: %s", captchaID, info.Type, info.Code)
 }
 
 // 获取验证码信息
@@ -54,3 +77,26 @@ func deleteCaptcha(captchaID string) {
 	delete(captchaMap, captchaID)
 	log.Info("Deleted captcha with ID: %s", captchaID)
 }
+
+// 一次性地取走验证码，仅当存储中仍是 info 时删除并返回 true，
+// 并发验证同一个验证码时只有一个调用能成功
+func consumeCaptcha(captchaID string, info *CaptchaInfo) bool {
+	captchaLock.Lock()
+	defer captchaLock.Unlock()
+
+	if captchaMap[captchaID] != info {
+		return false
+	}
+	delete(captchaMap, captchaID)
+	log.Info("Consumed captcha with ID: %s", captchaID)
+	return true
+}
+
+// 在指定时间后删除过期的验证码
+func expireCaptchaAfter(captchaID string, ttl time.Duration) {
+	go func() {
+		time.Sleep(ttl)
+		deleteCaptcha(captchaID)
+		log.Info("Deleted expired captcha with ID: %s", captchaID)
+	}()
+}
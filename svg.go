@@ -0,0 +1,265 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// 以 SVG 矢量图输出验证码：字符输出为字形轮廓路径，噪点和干扰线输出为路径
+//
+// 纯色背景输出为矩形，渐变、纹理和图片背景以内嵌 PNG 输出。
+// 字形轮廓不能原样输出，否则去掉平移后即可与字体的轮廓逐一比对得到答案：旋转、错切、缩放和
+// 按滤镜强度近似的正弦扭曲都直接计算到坐标中，每个控制点再加随机抖动，所有线段统一转换为
+// 随机细分的三次贝塞尔曲线，使路径的坐标和命令序列每次都不同。
+func renderCaptchaSVG(w io.Writer, code string, preset NoisePreset, style renderStyle) error {
+	layout, err := layoutCaptcha(code, style)
+	if err != nil {
+		return err
+	}
+	defer layout.release()
+	width, height := layout.width, layout.height
+
+	// 先光栅化背景，用于按背景选择字符颜色以及内嵌非纯色背景
+	bg := newCanvas(width, height)
+	defer releaseCanvas(bg)
+	if style.background != nil {
+		style.background.Draw(bg)
+	} else {
+		clear(bg.Pix)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		width, height, width, height)
+
+	// 背景
+	switch b := style.background.(type) {
+	case nil:
+	case SolidBackground:
+		fmt.Fprintf(&buf, `<rect width="100%%" height="100%%"%s/>`, svgPaint("fill", b.Color))
+	default:
+		var png bytes.Buffer
+		if err := pngEncoder.Encode(&png, bg); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, `<image width="%d" height="%d" href="data:image/png;base64,%s"/>`,
+			width, height, base64.StdEncoding.EncodeToString(png.Bytes()))
+	}
+
+	// 整张图共用的正弦扭曲，幅度按光栅扭曲滤镜的强度估算，波长为两个字号
+	wave := svgWave{
+		amp:    0.4 * svgDisplacement(preset.Filters, style.scale),
+		freq:   math.Pi / (fontSize * style.scale),
+		phaseX: rand.Float64() * 2 * math.Pi,
+		phaseY: rand.Float64() * 2 * math.Pi,
+	}
+
+	// 字符
+	var sfntBuf sfnt.Buffer
+	palette := make([]color.RGBA, 0, len(layout.slots))
+	for _, slot := range layout.slots {
+		col := pickGlyphColor(style, preset.ColorVariance, slotBackground(bg, slot, layout.baseline, style))
+		palette = append(palette, col)
+
+		// 以字形包围盒中心为轴变形
+		bounds, _, _ := slot.face.GlyphBounds(slot.char)
+		warp := newSVGGlyphWarp(
+			float64(slot.x)+fixedToFloat(bounds.Min.X+bounds.Max.X)/2,
+			float64(layout.baseline)+fixedToFloat(bounds.Min.Y+bounds.Max.Y)/2,
+			randomRotation(preset), wave, 0.012*layout.faces.size)
+		d, err := glyphPath(&sfntBuf, slot, layout.baseline, layout.faces.size, warp)
+		if err != nil {
+			return err
+		}
+		if d == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, `<path d="%s"%s/>`, d, svgPaint("fill", col))
+	}
+
+	// 噪点
+	dot, count := noiseDots(width, height, preset.NoiseDensity, style.scale)
+	if count > 0 {
+		buf.WriteString(`<path d="`)
+		for i := 0; i < count; i++ {
+			fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", rand.Intn(width), rand.Intn(height), dot, dot, dot)
+		}
+		fmt.Fprintf(&buf, `"%s/>`, svgPaint("fill", style.noiseColor))
+	}
+
+	// 干扰曲线，SVG 不支持渐变线宽，取两端线宽的平均值
+	for _, stroke := range curveStrokes(bg.Rect, scaledCurves(preset.Curves, style.scale), layout.slots, layout.baseline, palette) {
+		buf.WriteString(`<path d="`)
+		for i, p := range stroke.pts {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&buf, "%s%s %s", cmd, svgNum(p.x), svgNum(p.y))
+		}
+		fmt.Fprintf(&buf, `" fill="none"%s stroke-width="%s" stroke-linecap="round" stroke-linejoin="round"/>`,
+			svgPaint("stroke", stroke.col), svgNum((stroke.w0+stroke.w1)/2))
+	}
+
+	buf.WriteString(`</svg>`)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// 正弦扭曲：横坐标按纵坐标、纵坐标按横坐标做正弦位移
+type svgWave struct {
+	amp, freq      float64
+	phaseX, phaseY float64
+}
+
+// 单个字形的变形：以 (cx, cy) 为中心的线性变换（旋转、错切和缩放），再叠加正弦扭曲和随机抖动
+type svgGlyphWarp struct {
+	cx, cy     float64
+	a, b, c, d float64 // 线性变换矩阵 [a b; c d]
+	wave       svgWave
+	jitter     float64 // 每个坐标随机抖动的最大距离（像素）
+}
+
+func newSVGGlyphWarp(cx, cy, rad float64, wave svgWave, jitter float64) svgGlyphWarp {
+	shear := (rand.Float64()*2 - 1) * 0.2
+	sx, sy := 0.9+rand.Float64()*0.2, 0.9+rand.Float64()*0.2
+	sin, cos := math.Sincos(rad)
+	// 旋转 × 错切 × 缩放
+	return svgGlyphWarp{
+		cx: cx, cy: cy,
+		a: cos * sx, b: (cos*shear - sin) * sy,
+		c: sin * sx, d: (sin*shear + cos) * sy,
+		wave:   wave,
+		jitter: jitter,
+	}
+}
+
+func (w svgGlyphWarp) apply(p point) point {
+	x, y := p.x-w.cx, p.y-w.cy
+	q := point{w.cx + w.a*x + w.b*y, w.cy + w.c*x + w.d*y}
+	q.x += w.wave.amp * math.Sin(q.y*w.wave.freq+w.wave.phaseX)
+	q.y += w.wave.amp * math.Sin(q.x*w.wave.freq+w.wave.phaseY)
+	q.x += (rand.Float64()*2 - 1) * w.jitter
+	q.y += (rand.Float64()*2 - 1) * w.jitter
+	return q
+}
+
+// 将字符的字形轮廓转换为 SVG 路径数据，坐标平移到字符在画布中的位置并按 warp 变形
+//
+// 直线和二次曲线都转换为三次曲线，每段再随机细分为1到3段，只输出 M、C 和 Z 命令。
+func glyphPath(b *sfnt.Buffer, slot glyphSlot, baseline int, size float64, warp svgGlyphWarp) (string, error) {
+	index, err := slot.font.GlyphIndex(b, slot.char)
+	if err != nil {
+		return "", err
+	}
+	segments, err := slot.font.LoadGlyph(b, index, fixed.Int26_6(size*64), nil)
+	if err != nil {
+		return "", err
+	}
+
+	ox, oy := float64(slot.x), float64(baseline)
+	at := func(p fixed.Point26_6) point {
+		return point{ox + fixedToFloat(p.X), oy + fixedToFloat(p.Y)}
+	}
+	var d bytes.Buffer
+	write := func(cmd string, pts ...point) {
+		d.WriteString(cmd)
+		for i, p := range pts {
+			if i > 0 {
+				d.WriteByte(' ')
+			}
+			q := warp.apply(p)
+			d.WriteString(svgNum(q.x) + " " + svgNum(q.y))
+		}
+	}
+	var cur point
+	for i, seg := range segments {
+		var c1, c2, end point
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				d.WriteString("Z")
+			}
+			cur = at(seg.Args[0])
+			write("M", cur)
+			continue
+		case sfnt.SegmentOpLineTo:
+			end = at(seg.Args[0])
+			c1, c2 = lerp(cur, end, 1.0/3), lerp(cur, end, 2.0/3)
+		case sfnt.SegmentOpQuadTo:
+			ctrl := at(seg.Args[0])
+			end = at(seg.Args[1])
+			c1, c2 = lerp(cur, ctrl, 2.0/3), lerp(end, ctrl, 2.0/3)
+		case sfnt.SegmentOpCubeTo:
+			c1, c2, end = at(seg.Args[0]), at(seg.Args[1]), at(seg.Args[2])
+		}
+		// 按 de Casteljau 算法依次切下前 1/n、1/(n-1) ... 段
+		p0 := cur
+		for n := 1 + rand.Intn(3); n > 1; n-- {
+			t := 1 / float64(n)
+			a, b, c := lerp(p0, c1, t), lerp(c1, c2, t), lerp(c2, end, t)
+			ab, bc := lerp(a, b, t), lerp(b, c, t)
+			mid := lerp(ab, bc, t)
+			write("C", a, ab, mid)
+			p0, c1, c2 = mid, bc, c
+		}
+		write("C", c1, c2, end)
+		cur = end
+	}
+	if len(segments) > 0 {
+		d.WriteString("Z")
+	}
+	return d.String(), nil
+}
+
+func lerp(a, b point, t float64) point {
+	return point{a.x + (b.x-a.x)*t, a.y + (b.y-a.y)*t}
+}
+
+// 按滤镜链估算 SVG 正弦扭曲的幅度（像素）
+func svgDisplacement(filters []Filter, scale float64) float64 {
+	var d float64
+	for _, f := range filters {
+		switch f := f.(type) {
+		case WaveFilter:
+			d += math.Abs(f.Amplitude)
+		case ElasticFilter:
+			d += math.Abs(f.Alpha)
+		case SwirlFilter, PerspectiveFilter:
+			d += 2
+		}
+	}
+	return d * scale
+}
+
+// 颜色属性，半透明颜色额外输出不透明度
+func svgPaint(attr string, c color.RGBA) string {
+	if c.A == 0 {
+		return fmt.Sprintf(` %s="none"`, attr)
+	}
+	// color.RGBA 为预乘颜色，SVG 需要非预乘的颜色值；分量大于 alpha 的无效颜色按255处理
+	r, g, b := unpremultiply(min(c.R, c.A), c.A), unpremultiply(min(c.G, c.A), c.A), unpremultiply(min(c.B, c.A), c.A)
+	s := fmt.Sprintf(` %s="#%02x%02x%02x"`, attr, r, g, b)
+	if c.A != 255 {
+		s += fmt.Sprintf(` %s-opacity="%s"`, attr, svgNum(float64(c.A)/255))
+	}
+	return s
+}
+
+// 格式化坐标，保留两位小数
+func svgNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
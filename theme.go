@@ -0,0 +1,99 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"sync"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+const (
+	ThemeLight = "light" // 白色背景、深色字符
+	ThemeDark  = "dark"  // 深色背景、浅色字符
+)
+
+// Theme 颜色主题
+type Theme struct {
+	Name            string
+	BackgroundColor color.RGBA   // 背景色；使用透明背景时表示页面的背景色，用于对比度检查
+	Background      Background   // 可选的背景，为空时使用 BackgroundColor 纯色填充
+	Palette         []color.RGBA // 字符颜色，为空时按难度预设随机生成
+	NoiseColor      color.RGBA   // 噪点颜色
+	MinContrast     float64      // 调色板和随机字符颜色与背景色的最小对比度，为0时默认3；渲染时与 ImageConfig.MinContrast 取较大值
+}
+
+var (
+	themes = map[string]Theme{
+		ThemeLight: {
+			Name:            ThemeLight,
+			BackgroundColor: color.RGBA{255, 255, 255, 255},
+			Palette: []color.RGBA{
+				{31, 58, 147, 255},
+				{178, 34, 34, 255},
+				{0, 100, 0, 255},
+				{75, 0, 130, 255},
+				{139, 69, 19, 255},
+				{33, 33, 33, 255},
+			},
+			NoiseColor: color.RGBA{0, 0, 0, 255},
+		},
+		ThemeDark: {
+			Name:            ThemeDark,
+			BackgroundColor: color.RGBA{30, 30, 30, 255},
+			Palette: []color.RGBA{
+				{255, 209, 102, 255},
+				{6, 214, 160, 255},
+				{118, 200, 255, 255},
+				{255, 138, 128, 255},
+				{224, 224, 224, 255},
+				{199, 146, 234, 255},
+			},
+			NoiseColor: color.RGBA{200, 200, 200, 255},
+		},
+	}
+	themeLock sync.RWMutex
+)
+
+// RegisterTheme 注册自定义主题（例如品牌配色），也可覆盖内置主题
+//
+// 调色板中与背景色对比度低于 MinContrast 的颜色会被拒绝，并返回错误。
+func RegisterTheme(theme Theme) error {
+	if theme.Name == "" {
+		return errors.New("theme name must not be empty")
+	}
+	minContrast := theme.MinContrast
+	if minContrast == 0 {
+		minContrast = 3
+	}
+	for _, col := range theme.Palette {
+		if ratio := contrastRatio(col, theme.BackgroundColor); ratio < minContrast {
+			return fmt.Errorf("theme %q: colour %v has contrast %.2f against background, below %.2f",
+				theme.Name, col, ratio, minContrast)
+		}
+	}
+
+	themeLock.Lock()
+	defer themeLock.Unlock()
+	themes[theme.Name] = theme
+	log.Info("Registered theme: %s", theme.Name)
+	return nil
+}
+
+// 查找主题
+func lookupTheme(name string) (Theme, error) {
+	themeLock.RLock()
+	defer themeLock.RUnlock()
+
+	theme, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme: %q", name)
+	}
+	return theme, nil
+}
+
+// ContrastRatio 按 WCAG 定义计算两种颜色的对比度，范围 1~21，正文文字建议不低于4.5
+func ContrastRatio(a, b color.Color) float64 {
+	return contrastRatio(color.RGBAModel.Convert(a).(color.RGBA), color.RGBAModel.Convert(b).(color.RGBA))
+}
@@ -0,0 +1,89 @@
+package captcha
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRegisterThemeRejectsLowContrast(t *testing.T) {
+	err := RegisterTheme(Theme{
+		Name:            "test-brand",
+		BackgroundColor: color.RGBA{255, 255, 255, 255},
+		Palette:         []color.RGBA{{0, 82, 204, 255}, {250, 250, 200, 255}},
+	})
+	if err == nil {
+		t.Fatalf("与背景对比度过低的颜色应被拒绝")
+	}
+
+	err = RegisterTheme(Theme{
+		Name:            "test-brand",
+		BackgroundColor: color.RGBA{255, 255, 255, 255},
+		Palette:         []color.RGBA{{0, 82, 204, 255}},
+	})
+	if err != nil {
+		t.Fatalf("注册主题失败: %v", err)
+	}
+}
+
+func TestDarkTransparentTheme(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	SetImageConfig(ImageConfig{Theme: ThemeDark, Transparent: true})
+	img, err := createCaptchaImage("abcd", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	if a := img.RGBAAt(0, 0).A; a != 0 {
+		t.Fatalf("透明背景的角落应完全透明，alpha 为 %d", a)
+	}
+
+	dark, _ := lookupTheme(ThemeDark)
+	for _, col := range dark.Palette {
+		if ContrastRatio(col, dark.BackgroundColor) < 3 {
+			t.Fatalf("深色主题的颜色 %v 对比度不足", col)
+		}
+	}
+}
+
+func TestThemeMinContrastAppliedAtRender(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	if err := RegisterTheme(Theme{
+		Name:            "test-high-contrast",
+		BackgroundColor: color.RGBA{255, 255, 255, 255},
+		MinContrast:     7,
+	}); err != nil {
+		t.Fatalf("注册主题失败: %v", err)
+	}
+
+	// 图片配置的对比度更低或未设置时使用主题的对比度
+	for _, min := range []float64{0, 1, 4.5} {
+		SetImageConfig(ImageConfig{Theme: "test-high-contrast", MinContrast: min})
+		style, err := currentRenderStyle()
+		if err != nil {
+			t.Fatalf("解析绘制样式失败: %v", err)
+		}
+		if style.minContrast != 7 {
+			t.Fatalf("图片配置为 %v 时最小对比度应为主题的7，实际为 %v", min, style.minContrast)
+		}
+	}
+
+	SetImageConfig(ImageConfig{Theme: "test-high-contrast", MinContrast: 10})
+	if style, _ := currentRenderStyle(); style.minContrast != 10 {
+		t.Fatalf("图片配置的对比度更高时应使用图片配置，实际为 %v", style.minContrast)
+	}
+	img, err := createCaptchaImage("abcd", Simple)
+	if err != nil {
+		t.Fatalf("生成验证码失败: %v", err)
+	}
+	releaseCanvas(img)
+}
+
+func TestUnknownTheme(t *testing.T) {
+	defer SetImageConfig(ImageConfig{})
+
+	SetImageConfig(ImageConfig{Theme: "no-such-theme"})
+	if _, err := createCaptchaImage("abcd", Simple); err == nil {
+		t.Fatalf("未知主题应返回错误")
+	}
+}
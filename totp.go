@@ -0,0 +1,279 @@
+package captcha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/yowaimono/captcha/internal/log"
+)
+
+// OTPAlgorithm HOTP/TOTP 使用的 HMAC 哈希算法
+type OTPAlgorithm string
+
+const (
+	AlgorithmSHA1   OTPAlgorithm = "SHA1" // 默认算法，所有身份验证器应用都支持
+	AlgorithmSHA256 OTPAlgorithm = "SHA256"
+	AlgorithmSHA512 OTPAlgorithm = "SHA512"
+)
+
+// TOTPConfig 身份验证器（TOTP）配置
+type TOTPConfig struct {
+	Issuer    string        // 发行方名称，显示在身份验证器应用中
+	Digits    int           // 验证码位数，6到8位，默认6
+	Period    time.Duration // 时间步长，默认30秒
+	Algorithm OTPAlgorithm  // 哈希算法，默认 SHA1
+	Skew      int           // 验证时允许前后偏差的时间步数，用于容忍时钟误差，默认1；设为负数表示不允许偏差
+}
+
+// ErrInvalidOTPSecret 表示密钥不是有效的 base32 编码
+var ErrInvalidOTPSecret = errors.New("invalid OTP secret")
+
+var (
+	totpConfig = TOTPConfig{Digits: 6, Period: 30 * time.Second, Algorithm: AlgorithmSHA1, Skew: 1}
+	totpLock   sync.RWMutex
+
+	// 每个账号最后使用的时间步，与验证码存储分开，不能被 Verify 当作图片验证码使用
+	totpUsed     = make(map[string]totpUsage)
+	totpSweepAt  time.Time
+	totpUsedLock sync.Mutex // 同时保证同一账号的验证码检查和记录是原子的，防止并发重放
+)
+
+// 账号最后使用的时间步及其记录的过期时间
+type totpUsage struct {
+	step      uint64
+	expiresAt time.Time
+}
+
+// SetTOTPConfig 设置身份验证器配置
+func SetTOTPConfig(config TOTPConfig) error {
+	if config.Digits == 0 {
+		config.Digits = 6
+	}
+	if config.Period == 0 {
+		config.Period = 30 * time.Second
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = AlgorithmSHA1
+	}
+	if config.Skew == 0 {
+		config.Skew = 1
+	} else if config.Skew < 0 {
+		config.Skew = 0
+	}
+	if config.Digits < 6 || config.Digits > 8 {
+		return fmt.Errorf("TOTP digits must be between 6 and 8, got %d", config.Digits)
+	}
+	if config.Period < time.Second || config.Period%time.Second != 0 {
+		return fmt.Errorf("TOTP period must be a whole number of seconds, got %v", config.Period)
+	}
+	if _, err := otpHash(config.Algorithm); err != nil {
+		return err
+	}
+
+	totpLock.Lock()
+	defer totpLock.Unlock()
+	totpConfig = config
+	log.Info("Set TOTP config: issuer=%s, digits=%d, period=%v, algorithm=%s, skew=%d",
+		config.Issuer, config.Digits, config.Period, config.Algorithm, config.Skew)
+	return nil
+}
+
+func currentTOTPConfig() TOTPConfig {
+	totpLock.RLock()
+	defer totpLock.RUnlock()
+	return totpConfig
+}
+
+// GenerateOTPSecret 生成160位的随机密钥，返回不带填充的 base32 编码
+func GenerateOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		log.Error("Failed to generate OTP secret: %v", err)
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// HOTP 按 RFC 4226 计算基于计数器的一次性密码
+func HOTP(secret string, counter uint64, digits int, algorithm OTPAlgorithm) (string, error) {
+	key, err := decodeOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	newHash, err := otpHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter, digits, newHash), nil
+}
+
+// TOTP 按 RFC 6238 使用 SetTOTPConfig 的配置计算 t 时刻的一次性密码
+func TOTP(secret string, t time.Time) (string, error) {
+	config := currentTOTPConfig()
+	return HOTP(secret, totpStep(t, config.Period), config.Digits, config.Algorithm)
+}
+
+// VerifyHOTP 在 counter 到 counter+lookahead 的范围内验证 HOTP，
+// 验证通过时返回下一次应使用的计数器，调用方需保存该计数器以防止重放
+func VerifyHOTP(secret, code string, counter uint64, digits, lookahead int, algorithm OTPAlgorithm) (uint64, bool) {
+	key, err := decodeOTPSecret(secret)
+	if err != nil {
+		log.Warn("Failed to verify HOTP: %v", err)
+		return counter, false
+	}
+	newHash, err := otpHash(algorithm)
+	if err != nil {
+		log.Warn("Failed to verify HOTP: %v", err)
+		return counter, false
+	}
+	for i := 0; i <= lookahead; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter+uint64(i), digits, newHash)), []byte(code)) == 1 {
+			return counter + uint64(i) + 1, true
+		}
+	}
+	return counter, false
+}
+
+// VerifyTOTP 验证 account 的身份验证器验证码，允许 Skew 个时间步的时钟偏差
+//
+// 同一账号已经使用过的时间步（以及更早的时间步）的验证码会被拒绝，防止验证码被截获后重放；
+// 最后使用的时间步按账号单独记录，不与图片和短信验证码共用存储。
+func VerifyTOTP(account, secret, code string) bool {
+	return verifyTOTPAt(account, secret, code, time.Now())
+}
+
+func verifyTOTPAt(account, secret, code string, now time.Time) bool {
+	config := currentTOTPConfig()
+	key, err := decodeOTPSecret(secret)
+	if err != nil {
+		log.Warn("Failed to verify TOTP: %v", err)
+		return false
+	}
+	newHash, err := otpHash(config.Algorithm)
+	if err != nil {
+		log.Warn("Failed to verify TOTP: %v", err)
+		return false
+	}
+
+	current := totpStep(now, config.Period)
+	matched, found := uint64(0), false
+	for i := -config.Skew; i <= config.Skew; i++ {
+		if i < 0 && current < uint64(-i) {
+			continue
+		}
+		step := current + uint64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step, config.Digits, newHash)), []byte(code)) == 1 {
+			matched, found = step, true
+			break
+		}
+	}
+	if !found {
+		log.Warn("TOTP verification failed for account: %s", account)
+		return false
+	}
+
+	totpUsedLock.Lock()
+	defer totpUsedLock.Unlock()
+	// 每分钟最多清理一次过期的记录
+	if now.After(totpSweepAt) {
+		for k, u := range totpUsed {
+			if !now.Before(u.expiresAt) {
+				delete(totpUsed, k)
+			}
+		}
+		totpSweepAt = now.Add(time.Minute)
+	}
+	if last, ok := totpUsed[account]; ok && now.Before(last.expiresAt) && matched <= last.step {
+		log.Warn("TOTP replay rejected for account: %s", account)
+		return false
+	}
+	// 超出偏差窗口后旧的时间步本来就不会通过验证，记录可以过期
+	totpUsed[account] = totpUsage{
+		step:      matched,
+		expiresAt: now.Add(time.Duration(config.Skew*2+1) * config.Period),
+	}
+	log.Info("TOTP verification successful for account: %s", account)
+	return true
+}
+
+// TOTPURI 生成身份验证器应用扫码使用的 otpauth:// 配置链接
+func TOTPURI(account, secret string) string {
+	config := currentTOTPConfig()
+	label := account
+	if config.Issuer != "" {
+		label = config.Issuer + ":" + account
+	}
+	query := url.Values{}
+	query.Set("secret", strings.TrimRight(strings.ToUpper(secret), "="))
+	if config.Issuer != "" {
+		query.Set("issuer", config.Issuer)
+	}
+	query.Set("algorithm", string(config.Algorithm))
+	query.Set("digits", strconv.Itoa(config.Digits))
+	query.Set("period", strconv.Itoa(int(config.Period/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20")}
+	return u.String()
+}
+
+// TOTPQRCode 生成配置链接的二维码 PNG 图片，每个模块 scale 像素
+func TOTPQRCode(account, secret string, scale int) ([]byte, error) {
+	return QRCodePNG(TOTPURI(account, secret), scale)
+}
+
+// RFC 4226 第5.3节：HMAC 后动态截取31位，取模得到指定位数
+func hotp(key []byte, counter uint64, digits int, newHash func() hash.Hash) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(newHash, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func totpStep(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix()) / uint64(period/time.Second)
+}
+
+// 解码 base32 密钥，忽略大小写、空格和填充
+func decodeOTPSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOTPSecret, err)
+	}
+	return key, nil
+}
+
+func otpHash(algorithm OTPAlgorithm) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA1, "":
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTP algorithm: %q", algorithm)
+	}
+}
@@ -0,0 +1,179 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base32"
+	"image/png"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func base32Secret(s string) string {
+	return base32.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestHOTPRFC4226(t *testing.T) {
+	secret := base32Secret("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		got, err := HOTP(secret, uint64(counter), 6, AlgorithmSHA1)
+		if err != nil || got != code {
+			t.Errorf("HOTP(counter=%d) = %s, %v，期望 %s", counter, got, err, code)
+		}
+	}
+
+	next, ok := VerifyHOTP(secret, "969429", 1, 6, 3, AlgorithmSHA1)
+	if !ok || next != 4 {
+		t.Fatalf("VerifyHOTP 应在前瞻窗口内找到计数器3，实际 next=%d ok=%v", next, ok)
+	}
+	if _, ok := VerifyHOTP(secret, "969429", next, 6, 3, AlgorithmSHA1); ok {
+		t.Fatalf("已使用的计数器不应再次通过验证")
+	}
+}
+
+func TestTOTPRFC6238(t *testing.T) {
+	secrets := map[OTPAlgorithm]string{
+		AlgorithmSHA1:   base32Secret("12345678901234567890"),
+		AlgorithmSHA256: base32Secret("12345678901234567890123456789012"),
+		AlgorithmSHA512: base32Secret("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	vectors := []struct {
+		unix                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	for _, v := range vectors {
+		for alg, want := range map[OTPAlgorithm]string{AlgorithmSHA1: v.sha1, AlgorithmSHA256: v.sha256, AlgorithmSHA512: v.sha512} {
+			got, err := HOTP(secrets[alg], totpStep(time.Unix(v.unix, 0), 30*time.Second), 8, alg)
+			if err != nil || got != want {
+				t.Errorf("TOTP(%s, T=%d) = %s, %v，期望 %s", alg, v.unix, got, err, want)
+			}
+		}
+	}
+}
+
+// 设置身份验证器配置，测试结束时恢复
+func setTOTPConfig(t *testing.T, config TOTPConfig) {
+	saved := currentTOTPConfig()
+	if err := SetTOTPConfig(config); err != nil {
+		t.Fatalf("设置身份验证器配置失败: %v", err)
+	}
+	t.Cleanup(func() {
+		totpLock.Lock()
+		totpConfig = saved
+		totpLock.Unlock()
+	})
+}
+
+func TestVerifyTOTPSkewAndReplay(t *testing.T) {
+	setTOTPConfig(t, TOTPConfig{Issuer: "Example", Skew: 1})
+	secret, err := GenerateOTPSecret()
+	if err != nil || len(secret) != 32 {
+		t.Fatalf("生成密钥失败: %q %v", secret, err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	codeAt := func(offset time.Duration) string {
+		code, err := TOTP(secret, now.Add(offset))
+		if err != nil {
+			t.Fatalf("计算 TOTP 失败: %v", err)
+		}
+		return code
+	}
+
+	// 允许前后一个时间步的偏差，超出则拒绝
+	if !verifyTOTPAt("alice", secret, codeAt(-30*time.Second), now) {
+		t.Fatalf("上一个时间步的验证码应通过验证")
+	}
+	if verifyTOTPAt("bob", secret, codeAt(-90*time.Second), now) {
+		t.Fatalf("超出偏差窗口的验证码不应通过验证")
+	}
+
+	// 同一时间步的验证码只能使用一次，更早的时间步也不能再使用
+	if !verifyTOTPAt("carol", secret, codeAt(0), now) {
+		t.Fatalf("当前验证码应通过验证")
+	}
+	if verifyTOTPAt("carol", secret, codeAt(0), now.Add(time.Second)) {
+		t.Fatalf("重放的验证码不应通过验证")
+	}
+	if verifyTOTPAt("carol", secret, codeAt(-30*time.Second), now.Add(time.Second)) {
+		t.Fatalf("早于已使用时间步的验证码不应通过验证")
+	}
+	if !verifyTOTPAt("carol", secret, codeAt(30*time.Second), now.Add(30*time.Second)) {
+		t.Fatalf("下一个时间步的验证码应通过验证")
+	}
+	if verifyTOTPAt("dave", "not base32!", codeAt(0), now) {
+		t.Fatalf("无效密钥不应通过验证")
+	}
+}
+
+func TestTOTPReplayMarkerNotACaptcha(t *testing.T) {
+	setTOTPConfig(t, TOTPConfig{})
+	secret, err := GenerateOTPSecret()
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	code, err := TOTP(secret, time.Now())
+	if err != nil {
+		t.Fatalf("计算 TOTP 失败: %v", err)
+	}
+	if !VerifyTOTP("erin", secret, code) {
+		t.Fatalf("当前验证码应通过验证")
+	}
+
+	// 已使用的时间步不能被当作图片验证码通过 Verify
+	step := strconv.FormatUint(totpStep(time.Now(), 30*time.Second), 10)
+	for _, id := range []string{"erin", "totp:erin"} {
+		if Verify(id, step) {
+			t.Fatalf("TOTP 的重放记录不应通过 Verify(%q)", id)
+		}
+	}
+}
+
+func TestTOTPURIAndQRCode(t *testing.T) {
+	setTOTPConfig(t, TOTPConfig{Issuer: "Example Corp"})
+	secret := "JBSWY3DPEHPK3PXP"
+	uri := TOTPURI("alice@example.com", secret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("解析配置链接失败: %v", err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Example Corp:alice@example.com" ||
+		q.Get("secret") != secret || q.Get("issuer") != "Example Corp" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("配置链接错误: %s", uri)
+	}
+
+	data, err := TOTPQRCode("alice@example.com", secret, 4)
+	if err != nil {
+		t.Fatalf("生成二维码失败: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("解码二维码图片失败: %v", err)
+	}
+	// 每个模块4像素，版本 v 的边长为 (4v+17+8)*4
+	if w := img.Bounds().Dx(); w != img.Bounds().Dy() || (w/4-25)%4 != 0 {
+		t.Fatalf("二维码尺寸错误: %v", img.Bounds())
+	}
+}
+
+func TestQRCodeReedSolomon(t *testing.T) {
+	// ISO/IEC 18004 附录中 1-M "01234567" 的数据码字和纠错码字
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	want := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("纠错码字错误: % X，期望 % X", got, want)
+	}
+	if _, err := QRCodePNG(string(make([]byte, 500)), 1); err != ErrQRCodeTooLong {
+		t.Fatalf("内容过长时应返回 ErrQRCodeTooLong，实际为 %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package captcha
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// 标准库和 golang.org/x/image 只提供 WebP 解码器，这里实现一个简单的无损 WebP（VP8L）编码器：
+// 只使用减绿变换，不使用颜色缓存和元前缀码；后向引用只查找左侧和正上方的像素，
+// 对大面积纯色或渐变背景的验证码已有不错的压缩率。
+
+const (
+	vp8lMaxSize      = 1 << 14 // 宽高上限
+	vp8lMaxCodeBits  = 15      // 前缀码的最大码长
+	vp8lMaxMatch     = 4096    // 后向引用的最大长度
+	vp8lMinMatch     = 3       // 短于此长度的重复直接写字面量
+	vp8lLengthCodes  = 24
+	vp8lDistCodes    = 40
+	vp8lGreenSymbols = 256 + vp8lLengthCodes
+)
+
+// 码长码的写入顺序
+var vp8lCodeLengthOrder = [19]uint8{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// 按位从低到高写入的位流
+type vp8lBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (w *vp8lBitWriter) writeBits(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *vp8lBitWriter) flush() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nBits = 0, 0
+	}
+	return w.buf
+}
+
+// 一个像素（literal）或一次后向引用（length > 0）
+type vp8lToken struct {
+	argb   [4]uint8 // 减绿变换后的 R、G、B、A
+	length int
+	dist   int // 平面距离码
+}
+
+// 前缀码：写入码流头部的码长、按位反转后的码字以及实际写入的位数
+//
+// 只有一个符号时码长记为1，但解码器读取该符号时不消耗任何位
+type vp8lCode struct {
+	lengths []uint8
+	codes   []uint32
+	single  bool
+}
+
+func (c *vp8lCode) write(w *vp8lBitWriter, symbol int) {
+	if !c.single {
+		w.writeBits(c.codes[symbol], uint(c.lengths[symbol]))
+	}
+}
+
+// 转换为非预乘的 RGBA 并应用减绿变换，同时返回是否有不透明度低于255的像素
+//
+// 验证码画布是 *image.RGBA，直接从像素缓冲区读取，避免逐像素的接口调用；其他图片类型逐像素转换。
+func webpPixels(img image.Image) ([][4]uint8, bool) {
+	b := img.Bounds()
+	pix := make([][4]uint8, 0, b.Dx()*b.Dy())
+	hasAlpha := false
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			o := rgba.PixOffset(b.Min.X, y)
+			row := rgba.Pix[o : o+4*b.Dx() : o+4*b.Dx()]
+			for i := 0; i < len(row); i += 4 {
+				r, g, bl, a := row[i], row[i+1], row[i+2], row[i+3]
+				if a != 255 {
+					// 与 color.NRGBAModel 的取整方式一致
+					r, g, bl = unpremultiply(r, a), unpremultiply(g, a), unpremultiply(bl, a)
+					hasAlpha = true
+				}
+				pix = append(pix, [4]uint8{r - g, g, bl - g, a})
+			}
+		}
+		return pix, hasAlpha
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pix = append(pix, [4]uint8{c.R - c.G, c.G, c.B - c.G, c.A})
+			hasAlpha = hasAlpha || c.A != 255
+		}
+	}
+	return pix, hasAlpha
+}
+
+// 将预乘的颜色分量还原为非预乘的值
+func unpremultiply(v, a uint8) uint8 {
+	if a == 0 {
+		return 0
+	}
+	return uint8(uint32(v) * 0xffff / uint32(a) >> 8)
+}
+
+// encodeWebP 将图片编码为无损 WebP
+func encodeWebP(out io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return errors.New("webp: image size out of range")
+	}
+
+	pix, hasAlpha := webpPixels(img)
+	tokens := vp8lTokenize(pix, width)
+
+	// 统计各字母表的频率并生成前缀码
+	var green [vp8lGreenSymbols]int
+	var red, blue, alpha [256]int
+	var dist [vp8lDistCodes]int
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, _, _ := vp8lPrefix(t.length)
+			green[256+code]++
+			code, _, _ = vp8lPrefix(t.dist)
+			dist[code]++
+			continue
+		}
+		red[t.argb[0]]++
+		green[t.argb[1]]++
+		blue[t.argb[2]]++
+		alpha[t.argb[3]]++
+	}
+
+	w := &vp8lBitWriter{}
+	w.writeBits(0x2f, 8)
+	w.writeBits(uint32(width-1), 14)
+	w.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+	w.writeBits(0, 3) // 版本号
+
+	w.writeBits(1, 1) // 有变换
+	w.writeBits(2, 2) // 减绿变换
+	w.writeBits(0, 1) // 没有更多变换
+	w.writeBits(0, 1) // 不使用颜色缓存
+	w.writeBits(0, 1) // 不使用元前缀码
+
+	codes := [5]*vp8lCode{}
+	for i, freq := range [][]int{green[:], red[:], blue[:], alpha[:], dist[:]} {
+		codes[i] = vp8lBuildCode(freq, vp8lMaxCodeBits)
+		vp8lWriteCode(w, codes[i])
+	}
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, extraBits, extra := vp8lPrefix(t.length)
+			codes[0].write(w, 256+code)
+			w.writeBits(extra, extraBits)
+			code, extraBits, extra = vp8lPrefix(t.dist)
+			codes[4].write(w, code)
+			w.writeBits(extra, extraBits)
+			continue
+		}
+		codes[0].write(w, int(t.argb[1]))
+		codes[1].write(w, int(t.argb[0]))
+		codes[2].write(w, int(t.argb[2]))
+		codes[3].write(w, int(t.argb[3]))
+	}
+	data := w.flush()
+
+	// RIFF 容器，块大小为奇数时补一个字节
+	chunkSize := len(data)
+	padded := chunkSize + chunkSize&1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+	if padded != chunkSize {
+		data = append(data, 0)
+	}
+	_, err := out.Write(data)
+	return err
+}
+
+// 贪心查找与左侧像素（平面距离码 2）或正上方像素（平面距离码 1）重复的像素串
+func vp8lTokenize(pix [][4]uint8, width int) []vp8lToken {
+	tokens := make([]vp8lToken, 0, len(pix)/4)
+	for i := 0; i < len(pix); {
+		bestLen, bestDist := 0, 0
+		for _, cand := range [2][2]int{{1, 2}, {width, 1}} {
+			d, code := cand[0], cand[1]
+			if i < d {
+				continue
+			}
+			n := 0
+			for n < vp8lMaxMatch && i+n < len(pix) && pix[i+n] == pix[i+n-d] {
+				n++
+			}
+			if n > bestLen {
+				bestLen, bestDist = n, code
+			}
+		}
+		if bestLen >= vp8lMinMatch {
+			tokens = append(tokens, vp8lToken{length: bestLen, dist: bestDist})
+			i += bestLen
+			continue
+		}
+		tokens = append(tokens, vp8lToken{argb: pix[i]})
+		i++
+	}
+	return tokens
+}
+
+// 将长度或距离值（>= 1）转换为前缀码、额外位数和额外位的值
+func vp8lPrefix(v int) (code int, extraBits uint, extra uint32) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	hb := bits.Len(uint(d)) - 1
+	second := (d >> (hb - 1)) & 1
+	extraBits = uint(hb - 1)
+	return 2*hb + second, extraBits, uint32(d) & (1<<extraBits - 1)
+}
+
+// 根据频率生成码长不超过 limit 的范式前缀码，所有频率为0时使用符号0
+func vp8lBuildCode(freq []int, limit int) *vp8lCode {
+	f := append([]int(nil), freq...)
+	used := 0
+	for _, v := range f {
+		if v > 0 {
+			used++
+		}
+	}
+	if used == 0 {
+		f[0] = 1
+	}
+
+	var lengths []uint8
+	for {
+		lengths = huffmanLengths(f)
+		longest := uint8(0)
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if int(longest) <= limit {
+			break
+		}
+		// 码长超限时压缩频率分布后重试
+		for i, v := range f {
+			if v > 0 {
+				f[i] = (v + 1) / 2
+			}
+		}
+	}
+	return &vp8lCode{lengths: lengths, codes: canonicalCodes(lengths), single: used <= 1}
+}
+
+// 构造哈夫曼树并返回每个符号的码长，只有一个符号时码长为1（解码器读取时占0位）
+func huffmanLengths(freq []int) []uint8 {
+	type node struct {
+		weight      int
+		symbol      int
+		left, right int
+	}
+	var nodes []node
+	for s, v := range freq {
+		if v > 0 {
+			nodes = append(nodes, node{weight: v, symbol: s, left: -1, right: -1})
+		}
+	}
+	lengths := make([]uint8, len(freq))
+	if len(nodes) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+
+	// 双队列法：叶子已按权重排序，合并出的内部节点权重单调不减
+	leaves := len(nodes)
+	li, qi := 0, leaves
+	pop := func() int {
+		if li < leaves && (qi >= len(nodes) || nodes[li].weight <= nodes[qi].weight) {
+			li++
+			return li - 1
+		}
+		qi++
+		return qi - 1
+	}
+	for len(nodes)-leaves < leaves-1 {
+		a, b := pop(), pop()
+		nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, symbol: -1, left: a, right: b})
+	}
+
+	var walk func(n int, depth uint8)
+	walk = func(n int, depth uint8) {
+		if nodes[n].left < 0 {
+			lengths[nodes[n].symbol] = depth
+			return
+		}
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+	walk(len(nodes)-1, 0)
+	return lengths
+}
+
+// 由码长生成范式码字，并按位反转以便从低位开始写入
+func canonicalCodes(lengths []uint8) []uint32 {
+	var count [vp8lMaxCodeBits + 2]uint32
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+	codes := make([]uint32, len(lengths))
+	var next [vp8lMaxCodeBits + 2]uint32
+	code := uint32(0)
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l > 0 {
+			codes[s] = bits.Reverse32(next[l]) >> (32 - uint(l))
+			next[l]++
+		}
+	}
+	return codes
+}
+
+// 写入前缀码的码长，码长序列本身再用码长码（0~15 为码长，17、18 为连续的0）编码
+func vp8lWriteCode(w *vp8lBitWriter, c *vp8lCode) {
+	type clToken struct {
+		symbol int
+		extra  uint32
+	}
+	var tokens []clToken
+	var clFreq [19]int
+	lengths := c.lengths
+	for i := 0; i < len(lengths); {
+		if lengths[i] == 0 {
+			run := 1
+			for i+run < len(lengths) && lengths[i+run] == 0 && run < 138 {
+				run++
+			}
+			switch {
+			case run >= 11:
+				tokens = append(tokens, clToken{18, uint32(run - 11)})
+				i += run
+				clFreq[18]++
+				continue
+			case run >= 3:
+				tokens = append(tokens, clToken{17, uint32(run - 3)})
+				i += run
+				clFreq[17]++
+				continue
+			}
+		}
+		tokens = append(tokens, clToken{symbol: int(lengths[i])})
+		clFreq[lengths[i]]++
+		i++
+	}
+
+	clCode := vp8lBuildCode(clFreq[:], 7)
+	n := len(vp8lCodeLengthOrder)
+	for n > 4 && clCode.lengths[vp8lCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+
+	w.writeBits(0, 1) // 普通前缀码
+	w.writeBits(uint32(n-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:n] {
+		w.writeBits(uint32(clCode.lengths[s]), 3)
+	}
+	w.writeBits(0, 1) // 码长覆盖整个字母表
+	for _, t := range tokens {
+		clCode.write(w, t.symbol)
+		switch t.symbol {
+		case 17:
+			w.writeBits(t.extra, 3)
+		case 18:
+			w.writeBits(t.extra, 7)
+		}
+	}
+}